@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
+	// Embed the time zone database, so --timezone also works on systems without one
+	_ "time/tzdata"
 
 	"github.com/m10x/adspraygen/pkg"
 
@@ -17,38 +20,140 @@ var (
 	domain, ou, filter       string
 	mask                     string
 	maskFile                 string
+	maskTemplates            []string
 	outputFile               string
 	outputFormat             string
 	silent                   bool
 	cacheFile                string
 	noCache                  bool
 	forceRefresh             bool
+	onEmpty                  string
+	timezone                 string
+	locale                   string
+	localeFiles              []string
+	leetFiles                []string
+	nickFiles                []string
+	rulesFile                string
+	maxCandidates            int
+	maxPerUser               int
+	maxRounds                int
+	customCharsets           [4]string
+	charsetLimit             int
+	vars                     []string
+	varsFile                 string
+	policyFilter             bool
+	policyReport             string
 )
 
 var genCmd = &cobra.Command{
 	Use:     "gen",
 	Short:   "Query LDAP and generate spray credentials",
-	Long:    fmt.Sprintf("%s\n\n%s", getGenShortDescription(), getMaskOptions()),
 	Example: "adspraygen gen -d domain.local -u m10x -p m10x -s 10.10.10.10 -m 'Foobar{givenName#Reverse}{MonthGerman}{YYYY}!'",
 	Run: func(cmd *cobra.Command, args []string) {
-		var masks []string
+		loadLocaleFiles(localeFiles)
+		loadLeetFiles(leetFiles)
+		loadNickFiles(nickFiles)
+		setCustomCharsets(customCharsets)
+		loadVariables(vars, varsFile)
+		if _, _, ok := pkg.LookupLocale(locale); !ok {
+			pkg.PrintFatal(fmt.Sprintf("Unknown --locale %q, supported: %s", locale, strings.Join(pkg.SupportedLocales(), ", ")))
+		}
+
+		var lines []pkg.MaskLine
 		if maskFile != "" {
-			lines, err := pkg.ReadMaskFile(maskFile)
+			fileLines, err := pkg.ReadMaskFileLines(maskFile)
 			if err != nil {
 				pkg.PrintFatal(err.Error())
 			}
-			if len(lines) == 0 {
+			if len(fileLines) == 0 {
 				pkg.PrintFatal("Mask file is empty")
 			}
-			masks = lines
-		} else {
-			masks = []string{mask}
+			lines = fileLines
+		} else if mask != "" {
+			lines = []pkg.MaskLine{{Mask: mask}}
+		}
+
+		// Validate all masks before querying LDAP or writing any output
+		masks, invalid := compileMaskLines(maskFile, lines)
+		templates, invalidTemplates := loadMaskTemplates(maskTemplates)
+		masks, invalid = append(masks, templates...), invalid+invalidTemplates
+		if invalid > 0 {
+			pkg.PrintFatal(fmt.Sprintf("%d invalid mask(s), no output written. Use 'adspraygen mask lint' to check masks", invalid))
+		}
+
+		// Estimate the charset expansion before anything is generated
+		for _, m := range masks {
+			combinations := m.CharsetCombinations()
+			if combinations <= 1 {
+				continue
+			}
+			if combinations > charsetLimit {
+				pkg.PrintFatal(fmt.Sprintf("Mask %s expands to %d candidates per user from charsets, more than --charset-limit %d", m.Raw, combinations, charsetLimit))
+			}
+			// Every candidate of a user is a spray round of its own and each round is written to its own file
+			rounds := combinations
+			if maxCandidates > 0 {
+				rounds = min(rounds, maxCandidates)
+			}
+			if maxPerUser > 0 {
+				rounds = min(rounds, maxPerUser)
+			}
+			if maxRounds > 0 && rounds > maxRounds {
+				pkg.PrintFatal(fmt.Sprintf("Mask %s expands to %d candidates per user from charsets, that is %d spray rounds and output files, more than --max-rounds %d. Use --max-candidates or --max-per-user to keep fewer candidates per user", m.Raw, combinations, rounds, maxRounds))
+			}
+			pkg.PrintInfo(fmt.Sprintf("Mask %s expands to %d candidates per user from charsets, that is up to %d spray round(s) and output file(s)", m.Raw, combinations, rounds))
 		}
 
 		if strings.ToLower(outputFormat) != "kerbrute" && strings.ToLower(outputFormat) != "netexec" {
 			pkg.PrintFatal("Unknown outputFormat!")
 		}
 
+		switch strings.ToLower(onEmpty) {
+		case pkg.ON_EMPTY_SKIP, pkg.ON_EMPTY_KEEP, pkg.ON_EMPTY_FALLBACK:
+		default:
+			pkg.PrintFatal("Unknown --on-empty policy! Use skip, keep or fallback")
+		}
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			pkg.PrintFatal(fmt.Sprintf("Unknown --timezone %q: %v", timezone, err))
+		}
+		if maxCandidates < 0 {
+			pkg.PrintFatal("--max-candidates must be >= 0")
+		}
+		if maxPerUser < 0 {
+			pkg.PrintFatal("--max-per-user must be >= 0")
+		}
+		if maxRounds < 0 {
+			pkg.PrintFatal("--max-rounds must be >= 0")
+		}
+		opts := pkg.GenOptions{
+			OnEmpty:       strings.ToLower(onEmpty),
+			Location:      location,
+			Locale:        strings.ToLower(locale),
+			MaxCandidates: maxCandidates,
+			MaxPerUser:    maxPerUser,
+			MaxRounds:     maxRounds,
+			PolicyFilter:  policyFilter || policyReport != "",
+			PolicyReport:  policyReport,
+		}
+		if rulesFile != "" {
+			rules, ruleErrors, err := pkg.LoadRuleFile(rulesFile)
+			if err != nil {
+				pkg.PrintFatal(err.Error())
+			}
+			for _, ruleErr := range ruleErrors {
+				pkg.PrintError(fmt.Sprintf("%s: %v", rulesFile, ruleErr))
+			}
+			if len(ruleErrors) > 0 {
+				pkg.PrintFatal(fmt.Sprintf("%d invalid rule(s), no output written", len(ruleErrors)))
+			}
+			if len(rules) == 0 {
+				pkg.PrintFatal("Rule file is empty")
+			}
+			pkg.PrintInfo(fmt.Sprintf("Loaded %d rule(s) from %s", len(rules), rulesFile))
+			opts.Rules = rules
+		}
+
 		if ldapPort == -1 {
 			if ldapS {
 				ldapPort = 636
@@ -57,7 +162,7 @@ var genCmd = &cobra.Command{
 			}
 		}
 
-		pkg.RunLDAPQuery(ldapServer, ldapPort, ldapS, ntlm, username, password, hash, domain, ou, filter, outputFile, outputFormat, masks, pageSize, silent, cacheFile, noCache, forceRefresh)
+		pkg.RunLDAPQuery(ldapServer, ldapPort, ldapS, ntlm, username, password, hash, domain, ou, filter, outputFile, outputFormat, masks, opts, pageSize, silent, cacheFile, noCache, forceRefresh)
 	},
 }
 
@@ -79,6 +184,22 @@ func init() {
 	genCmd.Flags().StringVar(&outputFormat, "outputformat", "kerbrute", "Output format. kerbrute creates a single file with user:pass, netexec creates two files, one with user and one with pass")
 	genCmd.Flags().StringVarP(&mask, "mask", "m", "", "Password mask. E.g.: Foobar{givenName#Reverse}{MonthGerman}{YYYY}!")
 	genCmd.Flags().StringVar(&maskFile, "mask-file", "", "File with one mask per line (mutually exclusive with --mask)")
+	genCmd.Flags().StringArrayVar(&maskTemplates, "mask-template", nil, "File with a Go text/template mask, every non-empty output line is a candidate. Can be used multiple times")
+	genCmd.Flags().StringVar(&onEmpty, "on-empty", pkg.ON_EMPTY_SKIP, "Policy for placeholders without a value: skip drops the user from that mask, keep inserts an empty string, fallback uses the sAMAccountName")
+	genCmd.Flags().StringVar(&timezone, "timezone", "Local", "Time zone of the client used for month and season boundaries of date placeholders. E.g.: Europe/Berlin")
+	genCmd.Flags().StringVar(&locale, "locale", pkg.DEFAULT_LOCALE, "Default locale of {Month} and {Season}. Append -south for southern-hemisphere seasons, e.g. es-south")
+	genCmd.Flags().StringArrayVar(&localeFiles, "locale-file", nil, "JSON file with a custom locale (code, 12 months, 4 seasons). Can be used multiple times")
+	genCmd.Flags().StringArrayVar(&leetFiles, "leet-file", nil, "File with one leet rule like a>4,@ per line, usable as #Leet(<file name>). Can be used multiple times")
+	genCmd.Flags().StringArrayVar(&nickFiles, "nick-file", nil, "Nickname dictionary with one line like 'Robert: Bob, Rob' per name, usable as #Nick(<file name>). A file named en or de extends the built-in dictionary. Can be used multiple times")
+	genCmd.Flags().StringVar(&rulesFile, "rules", "", "Hashcat rule file applied to the candidates of every mask. Add the rule ':' to keep the unmodified candidates")
+	genCmd.Flags().IntVar(&maxCandidates, "max-candidates", 0, "Maximum number of candidates (spray rounds) per user and mask, 0 for no limit")
+	genCmd.Flags().IntVar(&maxPerUser, "max-per-user", 0, "Maximum number of candidates (spray rounds) per user over all masks, the best ranked ones are kept. 0 for no limit")
+	genCmd.Flags().IntVar(&maxRounds, "max-rounds", pkg.DEFAULT_MAX_ROUNDS, "Maximum number of spray rounds. Every round is written to its own output file, nothing is written if there are more. 0 for no limit")
+	addCustomCharsetFlags(genCmd, &customCharsets)
+	addVariableFlags(genCmd, &vars, &varsFile)
+	genCmd.Flags().IntVar(&charsetLimit, "charset-limit", pkg.DEFAULT_CHARSET_LIMIT, "Maximum number of candidates per user and mask that charsets like ?d?d?s may expand to. Every candidate is a spray round with its own output file, see --max-rounds")
+	genCmd.Flags().BoolVar(&policyFilter, "policy-filter", false, "Drop candidates the password policy of the user (domain or PSO) would reject: minimum length and, with complexity enabled, 3 of 5 character categories and no sAMAccountName or displayName tokens")
+	genCmd.Flags().StringVar(&policyReport, "policy-report", "", "File listing every candidate dropped by --policy-filter with the reason, grouped by mask. Enables --policy-filter")
 	genCmd.Flags().BoolVar(&silent, "silent", false, "Do not print the user attributes and the user:pass combos")
 	genCmd.Flags().StringVar(&cacheFile, "cache-file", "ldap_cache.json", "File to store cached LDAP data")
 	genCmd.Flags().BoolVar(&noCache, "no-cache", false, "Disable caching of LDAP data")
@@ -87,7 +208,7 @@ func init() {
 	genCmd.MarkFlagRequired("server")
 	genCmd.MarkFlagRequired("domain")
 	genCmd.MarkFlagsMutuallyExclusive("mask", "mask-file")
-	genCmd.MarkFlagsOneRequired("mask", "mask-file")
+	genCmd.MarkFlagsOneRequired("mask", "mask-file", "mask-template")
 }
 
 func getGenShortDescription() string {
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/m10x/adspraygen/pkg"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintMaskFile    string
+	lintTemplates   []string
+	lintLocaleFiles []string
+	lintLeetFiles   []string
+	lintNickFiles   []string
+	lintCharsets    [4]string
+	lintVars        []string
+	lintVarsFile    string
+)
+
+var maskCmd = &cobra.Command{
+	Use:   "mask",
+	Short: "Inspect and validate password masks",
+}
+
+var maskLintCmd = &cobra.Command{
+	Use:     "lint [mask...]",
+	Short:   "Validate password masks without querying LDAP",
+	Example: "adspraygen mask lint 'Foobar{givenName#Reverse}{MonthGerman}{YYYY}!'\nadspraygen mask lint --mask-file masks.txt",
+	Run: func(cmd *cobra.Command, args []string) {
+		loadLocaleFiles(lintLocaleFiles)
+		loadLeetFiles(lintLeetFiles)
+		loadNickFiles(lintNickFiles)
+		setCustomCharsets(lintCharsets)
+		loadVariables(lintVars, lintVarsFile)
+
+		var lines []pkg.MaskLine
+		for _, arg := range args {
+			lines = append(lines, pkg.MaskLine{Mask: arg})
+		}
+		if lintMaskFile != "" {
+			fileLines, err := pkg.ReadMaskFileLines(lintMaskFile)
+			if err != nil {
+				pkg.PrintFatal(err.Error())
+			}
+			lines = append(lines, fileLines...)
+		}
+		total := len(lines) + len(lintTemplates)
+		if total == 0 {
+			pkg.PrintFatal("Specify at least one mask, --mask-file or --mask-template")
+		}
+
+		_, invalid := compileMaskLines(lintMaskFile, lines)
+		_, invalidTemplates := loadMaskTemplates(lintTemplates)
+		if invalid += invalidTemplates; invalid > 0 {
+			pkg.PrintFatal(fmt.Sprintf("%d of %d mask(s) are invalid", invalid, total))
+		}
+		pkg.PrintSuccess(fmt.Sprintf("All %d mask(s) are valid", total))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(maskCmd)
+	maskCmd.AddCommand(maskLintCmd)
+
+	maskLintCmd.Flags().StringVar(&lintMaskFile, "mask-file", "", "File with one mask per line")
+	maskLintCmd.Flags().StringArrayVar(&lintTemplates, "mask-template", nil, "File with a Go text/template mask. Can be used multiple times")
+	maskLintCmd.Flags().StringArrayVar(&lintLocaleFiles, "locale-file", nil, "JSON file with a custom locale (code, 12 months, 4 seasons). Can be used multiple times")
+	addCustomCharsetFlags(maskLintCmd, &lintCharsets)
+	addVariableFlags(maskLintCmd, &lintVars, &lintVarsFile)
+	maskLintCmd.Flags().StringArrayVar(&lintLeetFiles, "leet-file", nil, "File with one leet rule like a>4,@ per line, usable as #Leet(<file name>). Can be used multiple times")
+	maskLintCmd.Flags().StringArrayVar(&lintNickFiles, "nick-file", nil, "Nickname dictionary with one line like 'Robert: Bob, Rob' per name, usable as #Nick(<file name>). Can be used multiple times")
+}
+
+// loadLocaleFiles loads custom locales, so that masks using them pass validation
+func loadLocaleFiles(paths []string) {
+	for _, path := range paths {
+		code, err := pkg.LoadLocaleFile(path)
+		if err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+		pkg.PrintInfo(fmt.Sprintf("Loaded locale %s from %s", code, path))
+	}
+}
+
+// loadLeetFiles loads custom leet tables, so that masks using them pass validation
+func loadLeetFiles(paths []string) {
+	for _, path := range paths {
+		name, err := pkg.LoadLeetFile(path)
+		if err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+		pkg.PrintInfo(fmt.Sprintf("Loaded leet table %s from %s", name, path))
+	}
+}
+
+// loadNickFiles loads custom nickname dictionaries, so that masks using them pass validation
+func loadNickFiles(paths []string) {
+	for _, path := range paths {
+		name, count, err := pkg.LoadNickFile(path)
+		if err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+		pkg.PrintInfo(fmt.Sprintf("Loaded %d name(s) into nickname dictionary %s from %s", count, name, path))
+	}
+}
+
+// addCustomCharsetFlags adds the hashcat-style -1 to -4 flags for the custom charsets ?1 to ?4
+func addCustomCharsetFlags(cmd *cobra.Command, charsets *[4]string) {
+	for i := range charsets {
+		id := fmt.Sprint(i + 1)
+		cmd.Flags().StringVarP(&charsets[i], "custom-charset"+id, id, "", fmt.Sprintf("Custom charset ?%s for masks, e.g. '?d!$' or '?l?u'", id))
+	}
+}
+
+// setCustomCharsets defines the custom charsets, so that masks using them pass validation
+func setCustomCharsets(charsets [4]string) {
+	for i, charset := range charsets {
+		if charset == "" {
+			continue
+		}
+		if err := pkg.SetCustomCharset(i+1, charset); err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+	}
+}
+
+// addVariableFlags adds the --var and --vars-file flags for {$name} variables
+func addVariableFlags(cmd *cobra.Command, vars *[]string, varsFile *string) {
+	cmd.Flags().StringArrayVar(vars, "var", nil, "Variable usable as {$name} in masks, e.g. --var company=Contoso. Can be used multiple times")
+	cmd.Flags().StringVar(varsFile, "vars-file", "", "File with one variable name=value per line")
+}
+
+// loadVariables sets the variables of the vars file first, so that --var can override them
+func loadVariables(assignments []string, file string) {
+	if file != "" {
+		count, err := pkg.LoadVariablesFile(file)
+		if err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+		pkg.PrintInfo(fmt.Sprintf("Loaded %d variable(s) from %s", count, file))
+	}
+	for _, assignment := range assignments {
+		name, value, err := pkg.ParseVariable(assignment)
+		if err == nil {
+			err = pkg.SetVariable(name, value)
+		}
+		if err != nil {
+			pkg.PrintFatal(err.Error())
+		}
+	}
+}
+
+// compileMaskLines parses and validates all masks, prints an error for every invalid
+// mask and returns the compiled masks together with the number of invalid ones
+func compileMaskLines(file string, lines []pkg.MaskLine) ([]*pkg.Mask, int) {
+	var masks []*pkg.Mask
+	invalid := 0
+	for _, line := range lines {
+		mask, err := pkg.CompileMask(line.Mask)
+		if err == nil {
+			err = pkg.CheckVariables(mask)
+		}
+		if err != nil {
+			location := "mask"
+			if line.Line > 0 {
+				location = fmt.Sprintf("%s:%d", file, line.Line)
+			}
+			printMaskError(location, err)
+			invalid++
+			continue
+		}
+		mask.Weight = line.Weight
+		masks = append(masks, mask)
+	}
+	return masks, invalid
+}
+
+// loadMaskTemplates parses and validates all template mask files like compileMaskLines
+func loadMaskTemplates(paths []string) ([]*pkg.Mask, int) {
+	var masks []*pkg.Mask
+	invalid := 0
+	for _, path := range paths {
+		mask, err := pkg.LoadTemplateMask(path)
+		if err == nil {
+			err = pkg.CheckVariables(mask)
+		}
+		if err != nil {
+			printMaskError(path, err)
+			invalid++
+			continue
+		}
+		masks = append(masks, mask)
+	}
+	return masks, invalid
+}
+
+// printMaskError prints a mask error with a caret pointing at the offending column
+func printMaskError(location string, err error) {
+	var maskErr *pkg.MaskError
+	if !errors.As(err, &maskErr) {
+		pkg.PrintError(fmt.Sprintf("%s: %v", location, err))
+		return
+	}
+
+	pointer := strings.ReplaceAll(maskErr.Pointer(), "\n", "\n  ")
+	pkg.PrintError(fmt.Sprintf("%s: %v\n  %s", location, maskErr, pointer))
+}
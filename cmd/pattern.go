@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/m10x/adspraygen/pkg"
@@ -19,24 +21,47 @@ var (
 	specialsFile string
 	patternOut   string
 	patternLimit int
+	keywalkSpec  string
+	classDefs    []string
+	allowRepeat  []string
 )
 
 var (
 	numberTokens     = []string{"{YY}", "{YYYY}", "1", "2", "3", "12", "123"}
 	specialTokens    = []string{"!", ".", "#", "-", "_"}
-	wordTokens       = []string{"{monthsBritish}", "{seasonsBritish}", "{sn}", "{givenName}"}
-	placeholderRegex = regexp.MustCompile(`(\[WORD\]|\[NUMBER\]|\[SPECIAL\]|\[EMPTY\])`)
+	wordTokens       = []string{"{MonthEnglish}", "{SeasonBritish}", "{sn}", "{givenName}"}
+	placeholderRegex = regexp.MustCompile(`\[(WORD|NUMBER|SPECIAL|KEYWALK|EMPTY|YEAR|MONTH|SEASON|CITY|CLASS:[A-Za-z0-9_-]+)\]`)
+	classNameRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// quantifierRegex matches a quantifier directly after a placeholder: {n}, {n,}, {n,m}, ? or +
+	quantifierRegex = regexp.MustCompile(`^(?:\{(\d+)(,(\d*))?\}|\?|\+)`)
+	// builtinClasses map further placeholder classes to the matching gen placeholders
+	builtinClasses = map[string][]string{
+		"[YEAR]":   {"{YYYY}", "{YY}"},
+		"[MONTH]":  {"{Month}", "{MM}"},
+		"[SEASON]": {"{Season}"},
+		"[CITY]":   {"{l}"},
+	}
 )
 
+// QUANTIFIER_MAX is the number of repetitions of [PH]+ and [PH]{n,}
+const QUANTIFIER_MAX = 3
+
+// charsetFollowers are the characters that make a '?' after a placeholder a mask charset like ?d instead of a quantifier
+const charsetFollowers = "dlusahH1234?"
+
 type patternToken struct {
 	kind  string
 	value string
+	// min and max are the number of repetitions of a placeholder, 1 without a quantifier
+	min, max int
 }
 
 var patternCmd = &cobra.Command{
 	Use:   "pattern",
 	Short: "Generate password masks from generic placeholder templates",
-	Long:  "Implements the same behavior as generatePatterns.py with [WORD], [NUMBER], and [SPECIAL] placeholders.",
+	Long: `Implements the same behavior as generatePatterns.py with [WORD], [NUMBER], [SPECIAL], [KEYWALK], [YEAR], [MONTH], [SEASON], [CITY] and [CLASS:name] placeholders.
+Placeholders take the quantifiers {n}, {n,m}, {n,} and ? and +, e.g. [NUMBER]{1,3} or [SPECIAL]?. A '?' that starts a mask charset like ?d stays a charset.
+A value is used at most once per class and mask unless --allow-repeat is given.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if (patternValue == "" && patternsFile == "") || (patternValue != "" && patternsFile != "") {
 			pkg.PrintFatal("Specify exactly one of --pattern or --patterns-file")
@@ -71,6 +96,29 @@ var patternCmd = &cobra.Command{
 			}
 			specials = custom
 		}
+		// Specials are literal characters, so '?', '(', '|', '{' and the like must not become mask syntax,
+		// e.g. a special '?' followed by a [NUMBER] would otherwise turn into a charset like ?1
+		escaped := make([]string, len(specials))
+		for i, special := range specials {
+			escaped[i] = pkg.EscapeMaskLiteral(special)
+		}
+		specials = escaped
+		// Words may contain placeholders like {sn}, everything else is literal
+		escaped = make([]string, len(words))
+		for i, word := range words {
+			escaped[i] = pkg.EscapeMaskWord(word)
+		}
+		words = escaped
+
+		// Keyboard walks are generated, so they are escaped to stay literal text in the masks
+		spec, err := pkg.ParseKeywalkSpec(keywalkSpec)
+		if err != nil {
+			pkg.PrintFatal(fmt.Sprintf("Invalid --keywalk: %v", err))
+		}
+		var keywalks []string
+		for _, walk := range pkg.KeyWalks(spec) {
+			keywalks = append(keywalks, pkg.EscapeMaskLiteral(walk))
+		}
 
 		patterns := []string{patternValue}
 		if patternsFile != "" {
@@ -80,6 +128,79 @@ var patternCmd = &cobra.Command{
 			}
 		}
 
+		classes := map[string][]string{
+			"[WORD]":    words,
+			"[NUMBER]":  numbers,
+			"[SPECIAL]": specials,
+			"[KEYWALK]": keywalks,
+			"[EMPTY]":   {""},
+		}
+		for class, values := range builtinClasses {
+			classes[class] = values
+		}
+		var classNames []string
+		for _, def := range classDefs {
+			name, values := loadClass(def)
+			classes["[CLASS:"+name+"]"] = values
+			classNames = append(classNames, name)
+		}
+
+		// Generated masks are concatenations of pattern text and values, so validating the values
+		// and every pattern rejects invalid masks before any output is written
+		invalid := validatePatternMasks("word", words) +
+			validatePatternMasks("number", numbers) +
+			validatePatternMasks("special", specials)
+		for _, name := range classNames {
+			invalid += validatePatternMasks("class "+name, classes["[CLASS:"+name+"]"])
+		}
+		repeat := map[string]bool{}
+		for _, class := range allowRepeat {
+			class = strings.TrimSpace(class)
+			if strings.EqualFold(class, "all") {
+				for name := range classes {
+					repeat[name] = true
+				}
+				continue
+			}
+			name := "[" + strings.Trim(class, "[]") + "]"
+			if _, ok := classes[name]; !ok {
+				pkg.PrintFatal(fmt.Sprintf("Invalid --allow-repeat %q, expected all or a class like NUMBER or CLASS:name", class))
+			}
+			repeat[name] = true
+		}
+
+		for _, patt := range patterns {
+			tokens, err := expandPattern(patt)
+			if err != nil {
+				pkg.PrintError(fmt.Sprintf("pattern %q: %v", patt, err))
+				invalid++
+				continue
+			}
+			// Values are self-contained mask parts, so the structure of the assembled mask only depends
+			// on the pattern text. Groups like (Sommer|[WORD]) may span placeholders.
+			var assembled strings.Builder
+			defined := true
+			for _, tok := range tokens {
+				values, known := classes[tok.value]
+				switch {
+				case tok.kind == "TEXT":
+					assembled.WriteString(tok.value)
+				case !known:
+					pkg.PrintError(fmt.Sprintf("pattern %q: %s is not defined, use --class name=file", patt, tok.value))
+					invalid++
+					defined = false
+				case len(values) > 0:
+					assembled.WriteString(values[0])
+				}
+			}
+			if defined {
+				invalid += validatePatternMasks(fmt.Sprintf("pattern %q", patt), []string{assembled.String()})
+			}
+		}
+		if invalid > 0 {
+			pkg.PrintFatal(fmt.Sprintf("%d invalid mask part(s), no output written", invalid))
+		}
+
 		var writer *bufio.Writer
 		var outFile *os.File
 		if patternOut != "" {
@@ -96,23 +217,29 @@ var patternCmd = &cobra.Command{
 		}
 
 		produced := 0
+		emit := func(value string) bool {
+			if patternLimit > 0 && produced >= patternLimit {
+				return false
+			}
+			if writer != nil {
+				_, err := writer.WriteString(value + "\n")
+				if err != nil {
+					pkg.PrintFatal(err.Error())
+				}
+			} else {
+				println(value)
+			}
+			produced++
+			return true
+		}
 		for _, patt := range patterns {
-			tokens := expandPattern(patt)
-			produceCombinations(tokens, words, numbers, specials, func(value string) bool {
+			tokens, _ := expandPattern(patt)
+			for _, variant := range expandQuantifiers(tokens) {
+				produceCombinations(variant, classes, repeat, emit)
 				if patternLimit > 0 && produced >= patternLimit {
-					return false
-				}
-				if writer != nil {
-					_, err := writer.WriteString(value + "\n")
-					if err != nil {
-						pkg.PrintFatal(err.Error())
-					}
-				} else {
-					println(value)
+					break
 				}
-				produced++
-				return true
-			})
+			}
 			if patternLimit > 0 && produced >= patternLimit {
 				break
 			}
@@ -130,6 +257,10 @@ func init() {
 	patternCmd.Flags().IntVar(&patternLimit, "limit", 0, "Maximum number of generated outputs (0 = unlimited)")
 	patternCmd.Flags().StringVar(&numbersFile, "numbers-file", "", "File with one number per line (uses defaults if not provided)")
 	patternCmd.Flags().StringVar(&specialsFile, "specials-file", "", "File with one special character per line (uses defaults if not provided)")
+	patternCmd.Flags().StringArrayVar(&classDefs, "class", nil, "Class for [CLASS:name] as name=file with one value per line, or name=a,b,c for an inline list. Can be used multiple times")
+	patternCmd.Flags().StringSliceVar(&allowRepeat, "allow-repeat", nil, "Allow a value to repeat within one mask, e.g. !! for [SPECIAL][SPECIAL]. Without a value for all classes, or only for the given ones, e.g. --allow-repeat=SPECIAL,CLASS:team")
+	patternCmd.Flags().Lookup("allow-repeat").NoOptDefVal = "all"
+	patternCmd.Flags().StringVar(&keywalkSpec, "keywalk", "", "Keyboard walks for [KEYWALK], e.g. layout=qwertz,length=4-6,direction=all,shift=first. Defaults to qwerty, length 4, right and down walks without shift")
 
 	patternCmd.MarkFlagsMutuallyExclusive("pattern", "patterns-file")
 	patternCmd.MarkFlagsOneRequired("pattern", "patterns-file")
@@ -157,6 +288,47 @@ func readNonEmptyLines(path string) []string {
 	return lines
 }
 
+// loadClass loads a --class definition, name=file reads one value per line and name=a,b,c is an
+// inline list. Values containing a comma are only possible in files.
+func loadClass(def string) (string, []string) {
+	name, source, ok := strings.Cut(def, "=")
+	name = strings.TrimSpace(name)
+	if !ok || !classNameRegex.MatchString(name) {
+		pkg.PrintFatal(fmt.Sprintf("Invalid --class %q, expected name=file or name=a,b,c with a name of letters, digits, '_' and '-'", def))
+	}
+
+	var values []string
+	if strings.Contains(source, ",") {
+		for _, value := range strings.Split(source, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	} else {
+		values = readNonEmptyLines(source)
+	}
+	if len(values) == 0 {
+		pkg.PrintFatal(fmt.Sprintf("No values loaded for class %s. Check --class %s", name, def))
+	}
+	// Like words, values may contain placeholders, everything else is literal
+	for i, value := range values {
+		values[i] = pkg.EscapeMaskWord(value)
+	}
+	return name, values
+}
+
+// validatePatternMasks checks that every token is a valid mask fragment and returns the number of invalid ones
+func validatePatternMasks(kind string, tokens []string) int {
+	invalid := 0
+	for _, token := range tokens {
+		if _, err := pkg.CompileMask(token); err != nil {
+			printMaskError(kind, err)
+			invalid++
+		}
+	}
+	return invalid
+}
+
 func buildwords(word []string) []string {
 	combined := append([]string{}, word...)
 	combined = append(combined, wordTokens...)
@@ -172,12 +344,12 @@ func buildwords(word []string) []string {
 	return out
 }
 
-func expandPattern(pattern string) []patternToken {
-	// Find all placeholder occurrences ([WORD], [NUMBER], [SPECIAL], [EMPTY]) in the input pattern.
+func expandPattern(pattern string) ([]patternToken, error) {
+	// Find all placeholder occurrences ([WORD], [NUMBER], [CLASS:name], ...) in the input pattern.
 	matches := placeholderRegex.FindAllStringIndex(pattern, -1)
 	// If no placeholders exist, the whole string is plain text.
 	if len(matches) == 0 {
-		return []patternToken{{kind: "TEXT", value: pattern}}
+		return []patternToken{{kind: "TEXT", value: escapeLoneQuestionMarks(pattern)}}, nil
 	}
 
 	// Build an ordered token stream by alternating literal text and placeholder tokens.
@@ -187,21 +359,96 @@ func expandPattern(pattern string) []patternToken {
 		start, end := m[0], m[1]
 		// Add text between the previous match and the current placeholder.
 		if start > last {
-			tokens = append(tokens, patternToken{kind: "TEXT", value: pattern[last:start]})
+			tokens = append(tokens, patternToken{kind: "TEXT", value: escapeLoneQuestionMarks(pattern[last:start])})
+		}
+		// Add the placeholder token itself with its quantifier, if any.
+		tok := patternToken{kind: "PH", value: pattern[start:end], min: 1, max: 1}
+		rest := pattern[end:]
+		switch q := quantifierRegex.FindStringSubmatch(rest); {
+		case strings.HasPrefix(rest, `\+`):
+			// An escaped '+' is a literal '+', only the backslash is skipped
+			end++
+		case q == nil, q[0] == "?" && len(rest) > 1 && strings.ContainsRune(charsetFollowers, rune(rest[1])):
+		case q[0] == "?":
+			tok.min, end = 0, end+1
+		case q[0] == "+":
+			tok.max, end = QUANTIFIER_MAX, end+1
+		default:
+			tok.min, _ = strconv.Atoi(q[1])
+			switch {
+			case q[2] == "":
+				tok.max = tok.min
+			case q[3] == "":
+				tok.max = max(tok.min, QUANTIFIER_MAX)
+			default:
+				tok.max, _ = strconv.Atoi(q[3])
+			}
+			if tok.max < tok.min || tok.max == 0 {
+				return nil, fmt.Errorf("invalid quantifier %s after %s", q[0], tok.value)
+			}
+			end += len(q[0])
 		}
-		// Add the placeholder token itself.
-		tokens = append(tokens, patternToken{kind: "PH", value: pattern[start:end]})
+		tokens = append(tokens, tok)
 		last = end
 	}
 	// Add trailing text after the final placeholder, if present.
 	if last < len(pattern) {
-		tokens = append(tokens, patternToken{kind: "TEXT", value: pattern[last:]})
+		tokens = append(tokens, patternToken{kind: "TEXT", value: escapeLoneQuestionMarks(pattern[last:])})
 	}
 
-	return tokens
+	return tokens, nil
 }
 
-func produceCombinations(tokens []patternToken, words []string, numbers []string, specials []string, emit func(string) bool) {
+// escapeLoneQuestionMarks escapes every '?' of pattern text that does not start a charset like ?d
+// or ?? within the text. Otherwise X? before [NUMBER] would turn into a charset like X?1.
+// The rest of the text keeps its mask syntax, e.g. {YYYY} or groups like (Sommer|[WORD]).
+func escapeLoneQuestionMarks(text string) string {
+	var escaped strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes):
+			escaped.WriteString(string(runes[i : i+2]))
+			i++
+		case runes[i] == '?' && i+1 < len(runes) && strings.ContainsRune(charsetFollowers, runes[i+1]):
+			escaped.WriteString(string(runes[i : i+2]))
+			i++
+		case runes[i] == '?':
+			escaped.WriteString(`\?`)
+		default:
+			escaped.WriteRune(runes[i])
+		}
+	}
+	return escaped.String()
+}
+
+// expandQuantifiers returns a token stream for every combination of repetition counts, fewer
+// repetitions first. The placeholders of the returned streams have no quantifier.
+func expandQuantifiers(tokens []patternToken) [][]patternToken {
+	variants := [][]patternToken{{}}
+	for _, tok := range tokens {
+		if tok.kind == "TEXT" {
+			for i := range variants {
+				variants[i] = append(variants[i], tok)
+			}
+			continue
+		}
+		var next [][]patternToken
+		for _, variant := range variants {
+			for count := tok.min; count <= tok.max; count++ {
+				repeated := append([]patternToken{}, variant...)
+				for i := 0; i < count; i++ {
+					repeated = append(repeated, patternToken{kind: tok.kind, value: tok.value, min: 1, max: 1})
+				}
+				next = append(next, repeated)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+func produceCombinations(tokens []patternToken, classes map[string][]string, repeat map[string]bool, emit func(string) bool) {
 	valuesByIndex := make([][]string, len(tokens))
 	typesByIndex := make([]string, len(tokens))
 
@@ -209,26 +456,20 @@ func produceCombinations(tokens []patternToken, words []string, numbers []string
 		switch {
 		case tok.kind == "TEXT":
 			valuesByIndex[i] = []string{tok.value}
-		case tok.value == "[WORD]":
-			valuesByIndex[i] = words
-			typesByIndex[i] = "WORD"
-		case tok.value == "[NUMBER]":
-			valuesByIndex[i] = numbers
-			typesByIndex[i] = "NUMBER"
-		case tok.value == "[SPECIAL]":
-			valuesByIndex[i] = specials
-			typesByIndex[i] = "SPECIAL"
-		case tok.value == "[EMPTY]":
-			valuesByIndex[i] = []string{""}
+		case tok.value == "[EMPTY]" || repeat[tok.value]:
+			valuesByIndex[i] = classes[tok.value]
 		default:
-			valuesByIndex[i] = []string{tok.value}
+			// A value is used at most once per class and candidate unless the class may repeat
+			valuesByIndex[i] = classes[tok.value]
+			typesByIndex[i] = tok.value
 		}
 	}
 
-	seen := map[string]map[string]bool{
-		"WORD":    {},
-		"NUMBER":  {},
-		"SPECIAL": {},
+	seen := map[string]map[string]bool{}
+	for _, typ := range typesByIndex {
+		if typ != "" {
+			seen[typ] = map[string]bool{}
+		}
 	}
 	chosen := make([]string, len(tokens))
 
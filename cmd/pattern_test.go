@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/m10x/adspraygen/pkg"
+)
+
+// generate returns every mask of a pattern like the pattern command does
+func generate(t *testing.T, pattern string, classes map[string][]string, repeat map[string]bool) []string {
+	t.Helper()
+	tokens, err := expandPattern(pattern)
+	if err != nil {
+		t.Fatalf("expandPattern(%q): %v", pattern, err)
+	}
+	var masks []string
+	for _, variant := range expandQuantifiers(tokens) {
+		produceCombinations(variant, classes, repeat, func(mask string) bool {
+			masks = append(masks, mask)
+			return true
+		})
+	}
+	return masks
+}
+
+func TestPatternMasks(t *testing.T) {
+	classes := map[string][]string{
+		"[WORD]":       {"{sn}"},
+		"[NUMBER]":     {"1", "2"},
+		"[SPECIAL]":    {"!", "."},
+		"[EMPTY]":      {""},
+		"[CLASS:team]": {"Bayern", "Dortmund"},
+	}
+	tests := []struct {
+		pattern string
+		repeat  map[string]bool
+		want    []string
+	}{
+		{"[WORD][NUMBER]", nil, []string{"{sn}1", "{sn}2"}},
+		{"[SPECIAL][SPECIAL]", nil, []string{"!.", ".!"}},
+		{"[SPECIAL][SPECIAL]", map[string]bool{"[SPECIAL]": true}, []string{"!!", "!.", ".!", ".."}},
+		{"[NUMBER]{1,2}", nil, []string{"1", "2", "12", "21"}},
+		{"[NUMBER]{2}x", nil, []string{"12x", "21x"}},
+		{"[SPECIAL]?x", nil, []string{"x", "!x", ".x"}},
+		{"[SPECIAL]?a", nil, []string{"!?a", ".?a"}},
+		{"[CLASS:team]+", nil, []string{"Bayern", "Dortmund", "BayernDortmund", "DortmundBayern"}},
+		{"a[EMPTY]b", nil, []string{"ab"}},
+		// A '?' that starts a charset stays one, any other '?' is literal
+		{"[WORD]?d", nil, []string{"{sn}?d"}},
+		{"X?[NUMBER]", nil, []string{`X\?1`, `X\?2`}},
+		{`[WORD]\+`, nil, []string{"{sn}+"}},
+		// Groups may span placeholders
+		{"(Sommer|[WORD])[SPECIAL]", nil, []string{"(Sommer|{sn})!", "(Sommer|{sn})."}},
+	}
+	for _, tt := range tests {
+		got := generate(t, tt.pattern, classes, tt.repeat)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pattern %q = %q, want %q", tt.pattern, got, tt.want)
+		}
+		for _, mask := range got {
+			if _, err := pkg.CompileMask(mask); err != nil {
+				t.Errorf("pattern %q generated the invalid mask %q: %v", tt.pattern, mask, err)
+			}
+		}
+	}
+}
+
+func TestExpandPatternErrors(t *testing.T) {
+	for _, pattern := range []string{"[NUMBER]{3,1}", "[SPECIAL]{0}", "[WORD]{0,0}"} {
+		if _, err := expandPattern(pattern); err == nil {
+			t.Errorf("expandPattern(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestEscapeLoneQuestionMarks(t *testing.T) {
+	tests := map[string]string{
+		"X?":      `X\?`,
+		"?d?x":    `?d\?x`,
+		"a??b":    "a??b",
+		`a\?b`:    `a\?b`,
+		"(a|b)?1": "(a|b)?1",
+	}
+	for text, want := range tests {
+		if got := escapeLoneQuestionMarks(text); got != want {
+			t.Errorf("escapeLoneQuestionMarks(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLoadClass(t *testing.T) {
+	name, values := loadClass("team= Bayern, Dortmund (BVB),")
+	if want := []string{"Bayern", `Dortmund \(BVB\)`}; name != "team" || !reflect.DeepEqual(values, want) {
+		t.Errorf("loadClass = %s %q, want team %q", name, values, want)
+	}
+}
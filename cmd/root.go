@@ -16,45 +16,106 @@ var (
 		Version: version,
 		Use:     "adspraygen",
 		Short:   "Active Directory password spray helper toolkit",
-		Long:    fmt.Sprintf("%s\nADSprayGen %s\n\nUse one of the available subcommands: gen, pattern, spray", getLogo(), version),
+		Long:    fmt.Sprintf("%s\nADSprayGen %s\n\nUse one of the available subcommands: gen, pattern, spray, mask", getLogo(), version),
 	}
 )
 
 func getMaskOptions() string {
-	return `
+	return fmt.Sprintf(`
 Mask Placeholders
 - {cn} : Full Name
 - {givenName} : First Name
 - {sn} : Last Name
 - {sAMAccountName} : Logon Name (Pre Windows 2000)
-- {rPrincipalName} : Logon Name
+- {userPrincipalName} : Logon Name
 - {description} : Description
 - {info} : Notes
 - {department} : Department
-- {I} : City
-- {postcalCode} : Postal Code
+- {l} : City
+- {postalCode} : Postal Code
+- {physicalDeliveryOfficeName} : Office / room
+- {telephoneNumber} : Phone number
+- {employeeID} : Employee ID
+- Multi-valued, only the first value unless #Each is used
+    - {proxyAddresses} : E-mail addresses, e.g. SMTP:john.doe@corp.local
+    - {otherTelephone} : Further phone numbers
+    - {memberOf} : Group DNs
+    - {servicePrincipalName} : SPNs of service accounts
 - Last password change
     - {YYYY} : e.g. 2024
     - {YY} : e.g. 24
     - {MM} : e.g. 01
     - {M} : e.g. 1
-    - {SeasonGerman} : e.g. Herbst
-    - {SeasonAmerican} : e.g. Fall
-    - {SeasonBritish} : e.g. Autumn
-    - {MonthGerman} : e.g. Januar
-    - {MonthEnglish} : e.g. January
+    - {Month} : e.g. January, {Month:fr} : e.g. Janvier
+    - {Season} : e.g. Autumn, {Season:de} : e.g. Herbst, {Season:es-south} : e.g. Primavera
+      Locales: %s
+      Append -south for southern-hemisphere seasons, load own locales with --locale-file
+    - {SeasonGerman} : e.g. Herbst (alias of {Season:de})
+    - {SeasonAmerican} : e.g. Fall (alias of {Season:en-us})
+    - {SeasonBritish} : e.g. Autumn (alias of {Season:en})
+    - {MonthGerman} : e.g. Januar (alias of {Month:de})
+    - {MonthEnglish} : e.g. January (alias of {Month:en})
+- Date offsets: append +n or -n (years for YYYY/YY, seasons for Season*, months otherwise)
+    - {YYYY-1} : e.g. 2023, {MonthEnglish+1} : e.g. February, {Season-1} : e.g. Summer
+- Date source prefix (default: pwdLastSet)
+    - {whenCreated:YYYY} : Account creation, e.g. for initial passwords
+    - {now:Month:de}     : Current date
+    - {lastLogonTimestamp:YY}, {accountExpires:MM}, {pwdLastSet:M}
+    - Use --timezone to compute month and season boundaries in the client's time zone
+- Predicted next password change (pwdLastSet + max. password age or msDS-UserPasswordExpiryTimeComputed)
+    - {next:YYYY}, {next:MonthGerman}, {next:SeasonBritish+1}, ...
+
+Keyboard Walks (every walk becomes its own candidate and spray round)
+- {keywalk}         : Walks of 4 keys to the right and down on QWERTY, e.g. 1234, qwer, 1qaz
+- {keywalk:layout=qwertz,length=4-8,direction=all,shift=first} : layout qwerty, qwertz or azerty, direction right, left, down, up or all,
+                      shift none, first or all, e.g. Qwertz, 1qay2wsx
+
+Variables (same value for every user, set with --var name=value or --vars-file)
+- {$company}        : User-defined variable, e.g. --var company=Contoso
+- {$domain}         : Domain FQDN, e.g. corp.contoso.com
+- {$domainLabel}    : First DNS label of the domain, e.g. corp
+- {$netbios}        : NetBIOS domain name, e.g. CORP
+- {$forest}         : Forest root domain, e.g. contoso.com
+
+Alternation (every alternative becomes its own candidate and spray round)
+- {givenName|sn}         : First Name or Last Name, modifiers apply to every alternative
+- (Sommer|Winter){YYYY}! : Alternatives in literal text, may contain placeholders. Parentheses without '|' stay literal
+
+Fallbacks (used when a placeholder is empty, see --on-empty for what happens otherwise)
+- {givenName?sn?sAMAccountName} : First non-empty value of the chain
+- {department:-Company}         : Literal default if the attribute is empty
+
+Charsets (hashcat-style, every character becomes its own candidate and spray round)
+- ?d ?l ?u ?s ?a    : Digits, lower, upper, specials, all   e.g. {givenName#Capitalize}?d?d?s
+- ?h ?H             : Lower / upper hex digits
+- ?1 ?2 ?3 ?4       : Custom charsets defined with -1 to -4, e.g. -1 '?d!$'
+- ??                : Literal '?', a '?' that is not followed by a charset stays literal as well
+
+Placeholder names are case-insensitive, modifier names are case-sensitive.
+Escape literal braces, parentheses, pipes and question marks as \{ \} \( \) \| \?. Use 'adspraygen mask lint' to validate masks.
 
 Mask Placeholder Modifiers (append to placeholder with #, chainable with multiple #)
-- #Upper            : Convert to uppercase                  e.g. {givenName#Upper} → JOHN
-- #Lower            : Convert to lowercase                  e.g. {givenName#Lower} → john
-- #Title            : Capitalize first letter of each word  e.g. {givenName#Title} → John Smith
-- #Capitalize       : Capitalize first letter only          e.g. {givenName#Capitalize} → John
-- #AlternateLower   : Alternating case, start lower         e.g. {givenName#AlternateLower} → jOhN
-- #AlternateUpper   : Alternating case, start upper         e.g. {givenName#AlternateUpper} → JoHn
-- #Reverse          : Reverse the string                    e.g. {givenName#Reverse} → nhoJ
-- #LeetBasic        : Substitute e→3, o→0, i→1, a→4
-- #LeetBasicPlus    : Substitute e→3, o→0, i→1, a→@, t→7
-- #Pattern(x>y)     : Replace x with y, chain rules with ;  e.g. {sn#Pattern(e>3;a>4)} → l33tspeak`
+%s
+%s
+Mask Directives
+- {!fit}            : Insert digits (123...) until the minimum password length of the user's policy (domain or PSO) is reached
+- {!fit:repeat(x)}  : Same, but repeat x instead, e.g. Summer{YYYY}{!fit:repeat(!)} → Summer2024!! for a minimum length of 12
+
+Template Masks (Go text/template, prefix a mask with tmpl: or use --mask-template file, every non-empty output line is a candidate)
+- {{.givenName}}        : Attribute of the user, {{attr "name"}} for any attribute, {{values "name"}} for all values.
+                          Printed empty values follow --on-empty, in conditions like {{if .department}} they stay empty
+- {{.sn | PadRight "!" 6}} : Modifiers are functions, the value comes last. {{expand "CasePerms" 3 .sn}} returns all values
+- {{date "YYYY"}}       : Date placeholder, {{var "company"}} a variable, {{policy}} the user's password policy
+- tmpl:{{if eq .department "IT"}}Admin{{else}}Welcome{{end}}{{date "YYYY"}}!`, strings.Join(pkg.SupportedLocales(), ", "), strings.Join(pkg.ModifierHelpLines(), "\n"), getPlaceholderHelp())
+}
+
+// getPlaceholderHelp lists the placeholders registered with pkg.RegisterPlaceholder, if any
+func getPlaceholderHelp() string {
+	lines := pkg.PlaceholderHelpLines()
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\nRegistered Placeholders\n" + strings.Join(lines, "\n") + "\n"
 }
 
 func getLogo() (logo string) {
@@ -77,6 +138,9 @@ func getLogo() (logo string) {
 }
 
 func Execute() {
+	// The help lists the registered modifiers, so it is built after all init functions had the chance to register theirs
+	genCmd.Long = fmt.Sprintf("%s\n\n%s", getGenShortDescription(), getMaskOptions())
+	maskLintCmd.Long = fmt.Sprintf("Parses every mask and reports unknown placeholders and modifiers, unbalanced braces and invalid Pattern() rules.\n\n%s", getMaskOptions())
 	if err := rootCmd.Execute(); err != nil {
 		pkg.PrintFatal(err.Error())
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
@@ -19,6 +20,10 @@ type CachedLDAPData struct {
 	LDAPServer     string          `json:"ldap_server"`
 	LDAPPort       int             `json:"ldap_port"`
 	PasswordPolicy *PasswordPolicy `json:"password_policy,omitempty"`
+	// PSOPolicies holds the readable fine-grained password policies keyed by lower-case DN
+	PSOPolicies map[string]*PasswordPolicy `json:"pso_policies,omitempty"`
+	// DomainInfo holds the NetBIOS and forest name of the domain
+	DomainInfo *DomainInfo `json:"domain_info,omitempty"`
 }
 
 // LDAPEntry represents a single LDAP entry
@@ -27,20 +32,22 @@ type LDAPEntry struct {
 	Attributes map[string][]string `json:"attributes"`
 }
 
-// PasswordPolicy holds the default domain password policy
+// PasswordPolicy holds the default domain password policy or a fine-grained password policy (PSO)
 type PasswordPolicy struct {
-	MinPwdLength              int   `json:"minPwdLength"`
-	PwdHistoryLength          int   `json:"pwdHistoryLength"`
-	MaxPwdAgeDays             int64 `json:"maxPwdAgeDays"`
-	MinPwdAgeDays             int64 `json:"minPwdAgeDays"`
-	PwdComplexity             bool  `json:"pwdComplexity"`
-	LockoutThreshold          int   `json:"lockoutThreshold"`
-	LockoutDurationMinutes    int64 `json:"lockoutDurationMinutes"`
-	LockoutObservationMinutes int64 `json:"lockoutObservationMinutes"`
+	Name                      string `json:"name,omitempty"`
+	Precedence                int    `json:"precedence,omitempty"`
+	MinPwdLength              int    `json:"minPwdLength"`
+	PwdHistoryLength          int    `json:"pwdHistoryLength"`
+	MaxPwdAgeDays             int64  `json:"maxPwdAgeDays"`
+	MinPwdAgeDays             int64  `json:"minPwdAgeDays"`
+	PwdComplexity             bool   `json:"pwdComplexity"`
+	LockoutThreshold          int    `json:"lockoutThreshold"`
+	LockoutDurationMinutes    int64  `json:"lockoutDurationMinutes"`
+	LockoutObservationMinutes int64  `json:"lockoutObservationMinutes"`
 }
 
 // SaveLDAPDataToCache stores the LDAP data in a JSON file
-func SaveLDAPDataToCache(entries []*ldap.Entry, searchBase, ldapFilter string, attributes []string, ldapServer string, ldapPort int, cacheFile string, policy *PasswordPolicy) error {
+func SaveLDAPDataToCache(entries []*ldap.Entry, searchBase, ldapFilter string, attributes []string, ldapServer string, ldapPort int, cacheFile string, policies PolicySet, domainInfo DomainInfo) error {
 	cachedData := CachedLDAPData{
 		CachedAt:       time.Now(),
 		SearchBase:     searchBase,
@@ -48,7 +55,9 @@ func SaveLDAPDataToCache(entries []*ldap.Entry, searchBase, ldapFilter string, a
 		Attributes:     attributes,
 		LDAPServer:     ldapServer,
 		LDAPPort:       ldapPort,
-		PasswordPolicy: policy,
+		PasswordPolicy: policies.Domain,
+		PSOPolicies:    policies.PSOs,
+		DomainInfo:     &domainInfo,
 	}
 
 	// Convert LDAP entries to cache format
@@ -117,7 +126,34 @@ func ConvertCacheToLDAPEntries(cachedData *CachedLDAPData) []*ldap.Entry {
 	return entries
 }
 
-// ShouldUpdateCache determines if the cache should be updated based on server information
-func ShouldUpdateCache(cachedData *CachedLDAPData, ldapServer string, ldapPort int) bool {
-	return cachedData.LDAPServer != ldapServer || cachedData.LDAPPort != ldapPort
+// ShouldUpdateCache determines if the cache should be updated because the server changed or because it
+// was written by an older version that did not query all UserAttributes or the domain information.
+// The reason is empty if the cache can be used.
+func ShouldUpdateCache(cachedData *CachedLDAPData, ldapServer string, ldapPort int) (bool, string) {
+	if cachedData.LDAPServer != ldapServer || cachedData.LDAPPort != ldapPort {
+		return true, "Server information changed"
+	}
+	var missing []string
+	for _, attribute := range UserAttributes {
+		if !containsFold(cachedData.Attributes, attribute) {
+			missing = append(missing, attribute)
+		}
+	}
+	if len(missing) > 0 {
+		return true, fmt.Sprintf("Cache lacks the attribute(s) %s", strings.Join(missing, ", "))
+	}
+	if cachedData.DomainInfo == nil {
+		return true, "Cache lacks the domain information"
+	}
+	return false, ""
+}
+
+// containsFold reports whether values contains value, case-insensitive
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
 }
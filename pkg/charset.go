@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DEFAULT_CHARSET_LIMIT is the default maximum number of charset combinations per user and mask
+const DEFAULT_CHARSET_LIMIT = 10000
+
+// customCharsets holds the custom charsets ?1 to ?4 set with SetCustomCharset
+var customCharsets = map[rune]string{}
+
+// SetCustomCharset defines the custom charset ?1 to ?4 like hashcat's -1 to -4, e.g. "?d!$" or "?l?u"
+func SetCustomCharset(id int, definition string) error {
+	if id < 1 || id > 4 {
+		return fmt.Errorf("custom charset %d does not exist, use 1 to 4", id)
+	}
+	charset, err := parseCharset(definition)
+	if err != nil {
+		return fmt.Errorf("custom charset %d: %v", id, err)
+	}
+	customCharsets[rune('0'+id)] = charset
+	return nil
+}
+
+// parseCharset expands the built-in charsets of a definition and removes duplicate characters.
+// ?? is a literal '?'.
+func parseCharset(definition string) (string, error) {
+	builtin := getBuiltinCharsets()
+	var charset strings.Builder
+	seen := map[rune]bool{}
+	add := func(chars string) {
+		for _, char := range chars {
+			if !seen[char] {
+				seen[char] = true
+				charset.WriteRune(char)
+			}
+		}
+	}
+
+	input := []rune(definition)
+	for i := 0; i < len(input); i++ {
+		if input[i] != '?' {
+			add(string(input[i]))
+			continue
+		}
+		if i+1 == len(input) {
+			return "", fmt.Errorf("trailing '?', use ?? for a literal '?'")
+		}
+		i++
+		if input[i] == '?' {
+			add("?")
+			continue
+		}
+		chars, ok := builtin[input[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown charset ?%c, use ?l ?u ?d ?s ?a ?h ?H", input[i])
+		}
+		add(chars)
+	}
+	if charset.Len() == 0 {
+		return "", fmt.Errorf("empty charset")
+	}
+	return charset.String(), nil
+}
+
+func isCharsetID(r rune) bool {
+	_, ok := getBuiltinCharsets()[r]
+	return ok || (r >= '1' && r <= '4')
+}
+
+// charsetChars returns the characters of a built-in or custom charset
+func charsetChars(id rune) (string, bool) {
+	if chars, ok := getBuiltinCharsets()[id]; ok {
+		return chars, true
+	}
+	chars, ok := customCharsets[id]
+	return chars, ok
+}
+
+// validateCharsets checks that every custom charset used by a mask is defined
+func validateCharsets(m *Mask) error {
+	var err error
+	walkNodes(m.Nodes, func(node MaskNode) {
+		if charset, ok := node.(*CharsetNode); ok && err == nil {
+			if _, ok := charsetChars(charset.Charset); !ok {
+				err = &MaskError{Mask: m.Raw, Column: charset.Col, Msg: fmt.Sprintf("custom charset ?%c is not defined, use -%c or --custom-charset%c", charset.Charset, charset.Charset, charset.Charset)}
+			}
+		}
+	})
+	return err
+}
+
+// CharsetCombinations estimates the number of candidates the charset tokens of a mask expand to
+// for a single user. Only groups with charsets count, their alternatives are added up. Plain
+// alternations like (Sommer|Winter) are not charsets. The result saturates at math.MaxInt.
+func (m *Mask) CharsetCombinations() int {
+	return charsetCombinations(m.Nodes)
+}
+
+func charsetCombinations(nodes []MaskNode) int {
+	total := 1
+	for _, node := range nodes {
+		count := 1
+		switch n := node.(type) {
+		case *CharsetNode:
+			chars, _ := charsetChars(n.Charset)
+			count = len([]rune(chars))
+		case *GroupNode:
+			if !containsCharset(n.Alternatives) {
+				break
+			}
+			count = 0
+			for _, alternative := range n.Alternatives {
+				count = saturatingAdd(count, charsetCombinations(alternative))
+			}
+		}
+		total = saturatingMul(total, count)
+	}
+	return total
+}
+
+// containsCharset reports whether any of the node sequences contains a charset token
+func containsCharset(sequences [][]MaskNode) bool {
+	found := false
+	for _, nodes := range sequences {
+		walkNodes(nodes, func(node MaskNode) {
+			if _, ok := node.(*CharsetNode); ok {
+				found = true
+			}
+		})
+	}
+	return found
+}
+
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
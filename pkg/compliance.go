@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// displayNameDelimiters split the displayName into the tokens AD checks passwords against
+const displayNameDelimiters = ",.-_ #\t"
+
+// droppedCandidate is a candidate removed because the user's policy would reject it
+type droppedCandidate struct {
+	username  string
+	candidate string
+	reason    string
+}
+
+// filterCompliant removes the candidates the policy of the user would reject and returns the
+// remaining candidates together with the dropped ones
+func filterCompliant(entry *ldap.Entry, policy *PasswordPolicy, candidates []string) ([]string, []droppedCandidate) {
+	if policy == nil {
+		return candidates, nil
+	}
+	username := entry.GetAttributeValue("sAMAccountName")
+	var kept []string
+	var dropped []droppedCandidate
+	for _, candidate := range candidates {
+		if reason := policyViolation(entry, policy, candidate); reason != "" {
+			dropped = append(dropped, droppedCandidate{username: username, candidate: candidate, reason: reason})
+			continue
+		}
+		kept = append(kept, candidate)
+	}
+	return kept, dropped
+}
+
+// policyViolation returns why AD would reject the password for the user, or "" if it complies.
+// With complexity enabled the password needs three of five character categories and must not
+// contain the sAMAccountName or any displayName token of three or more characters.
+func policyViolation(entry *ldap.Entry, policy *PasswordPolicy, password string) string {
+	if length := utf8.RuneCountInString(password); length < policy.MinPwdLength {
+		return fmt.Sprintf("shorter than %d characters", policy.MinPwdLength)
+	}
+	if !policy.PwdComplexity {
+		return ""
+	}
+	if categories := characterCategories(password); categories < 3 {
+		return fmt.Sprintf("only %d of 5 character categories", categories)
+	}
+
+	lower := strings.ToLower(password)
+	// The sAMAccountName is only checked if it is at least three characters long
+	if username := entry.GetAttributeValue("sAMAccountName"); utf8.RuneCountInString(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		return "contains the sAMAccountName"
+	}
+	for _, token := range displayNameTokens(entry.GetAttributeValue("displayName")) {
+		if strings.Contains(lower, strings.ToLower(token)) {
+			return fmt.Sprintf("contains the displayName token %q", token)
+		}
+	}
+	return ""
+}
+
+// characterCategories counts the AD complexity categories of a password: upper-case letters,
+// lower-case letters, digits, non-alphanumeric characters and letters without case
+func characterCategories(password string) int {
+	var upper, lower, digit, special, caseless bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case unicode.IsLetter(r):
+			caseless = true
+		default:
+			special = true
+		}
+	}
+	count := 0
+	for _, found := range []bool{upper, lower, digit, special, caseless} {
+		if found {
+			count++
+		}
+	}
+	return count
+}
+
+// displayNameTokens splits a displayName at AD's delimiters and drops tokens shorter than three characters
+func displayNameTokens(displayName string) []string {
+	var tokens []string
+	for _, token := range strings.FieldsFunc(displayName, func(r rune) bool {
+		return strings.ContainsRune(displayNameDelimiters, r)
+	}) {
+		if utf8.RuneCountInString(token) >= 3 {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// printDroppedSummary prints how many candidates of a mask were dropped per reason
+func printDroppedSummary(mask string, dropped []droppedCandidate) {
+	reasons := map[string]int{}
+	for _, d := range dropped {
+		// Group "contains the displayName token X" by its kind
+		reason, _, _ := strings.Cut(d.reason, " \"")
+		reasons[reason]++
+	}
+	keys := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		keys = append(keys, reason)
+	}
+	sort.Strings(keys)
+
+	fmt.Println()
+	PrintWarning(fmt.Sprintf("Dropped %d candidate(s) the password policy would reject (mask: %s)", len(dropped), mask))
+	for _, reason := range keys {
+		PrintWarning(fmt.Sprintf("  %d %s", reasons[reason], reason))
+	}
+}
+
+// writePolicyReport writes every dropped candidate with its reason, grouped by mask
+func writePolicyReport(path string, masks []*Mask, dropped [][]droppedCandidate) {
+	file, path := createFile(path, COMBO)
+	if file == nil {
+		return
+	}
+	defer file.Close()
+
+	total := 0
+	for i, mask := range masks {
+		if len(dropped[i]) == 0 {
+			continue
+		}
+		appendToFile(file, fmt.Sprintf("# Mask: %s", mask.Raw))
+		for _, d := range dropped[i] {
+			appendToFile(file, fmt.Sprintf("%s:%s\t%s", d.username, d.candidate, d.reason))
+		}
+		total += len(dropped[i])
+	}
+	fmt.Println()
+	PrintSuccess(fmt.Sprintf("Policy report with %d dropped candidate(s) written to %s", total, path))
+}
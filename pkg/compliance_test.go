@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyViolation(t *testing.T) {
+	complex := &PasswordPolicy{MinPwdLength: 8, PwdComplexity: true}
+	simple := &PasswordPolicy{MinPwdLength: 8}
+	tests := []struct {
+		policy   *PasswordPolicy
+		password string
+		want     string
+	}{
+		{simple, "summer24", ""},
+		{simple, "short", "shorter than 8 characters"},
+		{simple, "Jürgen1!", ""},
+		{complex, "Summer2024!", ""},
+		{complex, "summer2024", "only 2 of 5 character categories"},
+		{complex, "Summer2024", ""},
+		{complex, "Jdoe2024!", "contains the sAMAccountName"},
+		{complex, "xSMITH2024!", `contains the displayName token "Smith"`},
+		{complex, "Jo2024!Hn", ""},
+		// Letters without case like Japanese kana count as a category of their own
+		{complex, "ありがとう12a", ""},
+	}
+	for _, tt := range tests {
+		if got := policyViolation(testEntry(), tt.policy, tt.password); got != tt.want {
+			t.Errorf("policyViolation(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestFilterCompliant(t *testing.T) {
+	policy := &PasswordPolicy{MinPwdLength: 10, PwdComplexity: true}
+	kept, dropped := filterCompliant(testEntry(), policy, []string{"Summer2024!", "Summer24!", "Doe-Smith2024!", "Winter2024!"})
+	if want := []string{"Summer2024!", "Winter2024!"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("filterCompliant kept %q, want %q", kept, want)
+	}
+	if len(dropped) != 2 || dropped[0].username != "jdoe" || dropped[0].candidate != "Summer24!" {
+		t.Errorf("filterCompliant dropped %+v, want Summer24! and Doe-Smith2024! of jdoe", dropped)
+	}
+
+	candidates := []string{"a", "b"}
+	if kept, dropped := filterCompliant(testEntry(), nil, candidates); !reflect.DeepEqual(kept, candidates) || dropped != nil {
+		t.Errorf("filterCompliant without a policy = %q, %v, want all candidates", kept, dropped)
+	}
+}
+
+func TestDisplayNameTokens(t *testing.T) {
+	got := displayNameTokens("Doe, John-Paul de la Cruz_Jr.#IT")
+	want := []string{"Doe", "John", "Paul", "Cruz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("displayNameTokens = %q, want %q", got, want)
+	}
+}
@@ -1,29 +1,113 @@
 package pkg
 
-func getGermanSeasons() []string {
-	return []string{"Frühling", "Sommer", "Herbst", "Winter"}
-}
-
-func getAmericanSeasons() []string {
-	return []string{"Spring", "Summer", "Fall", "Winter"}
-}
-
-func getBritishSeasons() []string {
-	return []string{"Spring", "Summer", "Autumn", "Winter"}
-}
-
-func getGermanMonths() []string {
-	return []string{
-		"Januar", "Februar", "März", "April", "Mai", "Juni",
-		"Juli", "August", "September", "Oktober", "November", "Dezember",
-	}
-}
-
-func getEnglishMonths() []string {
-	return []string{
+// getBuiltinLocales returns the built-in month and season names by locale code.
+// Seasons are ordered spring, summer, autumn, winter.
+func getBuiltinLocales() map[string]Locale {
+	englishMonths := []string{
 		"January", "February", "March", "April", "May", "June",
 		"July", "August", "September", "October", "November", "December",
 	}
+	return map[string]Locale{
+		"de": {
+			Months: []string{
+				"Januar", "Februar", "März", "April", "Mai", "Juni",
+				"Juli", "August", "September", "Oktober", "November", "Dezember",
+			},
+			Seasons: []string{"Frühling", "Sommer", "Herbst", "Winter"},
+		},
+		"en": {
+			Months:  englishMonths,
+			Seasons: []string{"Spring", "Summer", "Autumn", "Winter"},
+		},
+		"en-us": {
+			Months:  englishMonths,
+			Seasons: []string{"Spring", "Summer", "Fall", "Winter"},
+		},
+		"fr": {
+			Months: []string{
+				"Janvier", "Février", "Mars", "Avril", "Mai", "Juin",
+				"Juillet", "Août", "Septembre", "Octobre", "Novembre", "Décembre",
+			},
+			Seasons: []string{"Printemps", "Été", "Automne", "Hiver"},
+		},
+		"es": {
+			Months: []string{
+				"Enero", "Febrero", "Marzo", "Abril", "Mayo", "Junio",
+				"Julio", "Agosto", "Septiembre", "Octubre", "Noviembre", "Diciembre",
+			},
+			Seasons: []string{"Primavera", "Verano", "Otoño", "Invierno"},
+		},
+		"it": {
+			Months: []string{
+				"Gennaio", "Febbraio", "Marzo", "Aprile", "Maggio", "Giugno",
+				"Luglio", "Agosto", "Settembre", "Ottobre", "Novembre", "Dicembre",
+			},
+			Seasons: []string{"Primavera", "Estate", "Autunno", "Inverno"},
+		},
+		"nl": {
+			Months: []string{
+				"Januari", "Februari", "Maart", "April", "Mei", "Juni",
+				"Juli", "Augustus", "September", "Oktober", "November", "December",
+			},
+			Seasons: []string{"Lente", "Zomer", "Herfst", "Winter"},
+		},
+		"pl": {
+			Months: []string{
+				"Styczeń", "Luty", "Marzec", "Kwiecień", "Maj", "Czerwiec",
+				"Lipiec", "Sierpień", "Wrzesień", "Październik", "Listopad", "Grudzień",
+			},
+			Seasons: []string{"Wiosna", "Lato", "Jesień", "Zima"},
+		},
+		"sv": {
+			Months: []string{
+				"Januari", "Februari", "Mars", "April", "Maj", "Juni",
+				"Juli", "Augusti", "September", "Oktober", "November", "December",
+			},
+			Seasons: []string{"Vår", "Sommar", "Höst", "Vinter"},
+		},
+		"da": {
+			Months: []string{
+				"Januar", "Februar", "Marts", "April", "Maj", "Juni",
+				"Juli", "August", "September", "Oktober", "November", "December",
+			},
+			Seasons: []string{"Forår", "Sommer", "Efterår", "Vinter"},
+		},
+		"no": {
+			Months: []string{
+				"Januar", "Februar", "Mars", "April", "Mai", "Juni",
+				"Juli", "August", "September", "Oktober", "November", "Desember",
+			},
+			Seasons: []string{"Vår", "Sommer", "Høst", "Vinter"},
+		},
+		"fi": {
+			Months: []string{
+				"Tammikuu", "Helmikuu", "Maaliskuu", "Huhtikuu", "Toukokuu", "Kesäkuu",
+				"Heinäkuu", "Elokuu", "Syyskuu", "Lokakuu", "Marraskuu", "Joulukuu",
+			},
+			Seasons: []string{"Kevät", "Kesä", "Syksy", "Talvi"},
+		},
+		"pt": {
+			Months: []string{
+				"Janeiro", "Fevereiro", "Março", "Abril", "Maio", "Junho",
+				"Julho", "Agosto", "Setembro", "Outubro", "Novembro", "Dezembro",
+			},
+			Seasons: []string{"Primavera", "Verão", "Outono", "Inverno"},
+		},
+		"cs": {
+			Months: []string{
+				"Leden", "Únor", "Březen", "Duben", "Květen", "Červen",
+				"Červenec", "Srpen", "Září", "Říjen", "Listopad", "Prosinec",
+			},
+			Seasons: []string{"Jaro", "Léto", "Podzim", "Zima"},
+		},
+		"tr": {
+			Months: []string{
+				"Ocak", "Şubat", "Mart", "Nisan", "Mayıs", "Haziran",
+				"Temmuz", "Ağustos", "Eylül", "Ekim", "Kasım", "Aralık",
+			},
+			Seasons: []string{"İlkbahar", "Yaz", "Sonbahar", "Kış"},
+		},
+	}
 }
 
 func getLeetBasic() map[rune]string {
@@ -44,3 +128,208 @@ func getLeetBasicPlus() map[rune]string {
 		'T': "7",
 	}
 }
+
+// getTransliterations returns the transliteration tables usable with #Translit by language code
+func getTransliterations() map[string]map[rune]string {
+	scandinavian := map[rune]string{
+		'æ': "ae", 'Æ': "Ae",
+		'ø': "oe", 'Ø': "Oe",
+		'å': "aa", 'Å': "Aa",
+	}
+	return map[string]map[rune]string{
+		"de": {
+			'ä': "ae", 'Ä': "Ae",
+			'ö': "oe", 'Ö': "Oe",
+			'ü': "ue", 'Ü': "Ue",
+			'ß': "ss", 'ẞ': "SS",
+		},
+		"da": scandinavian,
+		"no": scandinavian,
+	}
+}
+
+// getASCIIFallbacks returns ASCII spellings for letters that have no Unicode decomposition
+func getASCIIFallbacks() map[rune]string {
+	return map[rune]string{
+		'ß': "ss", 'ẞ': "SS",
+		'æ': "ae", 'Æ': "AE",
+		'œ': "oe", 'Œ': "OE",
+		'ø': "o", 'Ø': "O",
+		'ł': "l", 'Ł': "L",
+		'đ': "d", 'Đ': "D",
+		'ð': "d", 'Ð': "D",
+		'þ': "th", 'Þ': "Th",
+		'ı': "i",
+	}
+}
+
+// getBuiltinCharsets returns the hashcat built-in charsets by their mask character
+func getBuiltinCharsets() map[rune]string {
+	lower := "abcdefghijklmnopqrstuvwxyz"
+	upper := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits := "0123456789"
+	specials := " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	return map[rune]string{
+		'l': lower,
+		'u': upper,
+		'd': digits,
+		's': specials,
+		'a': lower + upper + digits + specials,
+		'h': digits + "abcdef",
+		'H': digits + "ABCDEF",
+	}
+}
+
+// getNicknames returns the built-in nickname dictionaries usable with #Nick by language code.
+// Names are lower-case, the nicknames are tried in the order listed.
+func getNicknames() map[string]map[string][]string {
+	return map[string]map[string][]string{
+		"en": {
+			"abigail":     {"abby"},
+			"alexander":   {"alex", "xander"},
+			"alexandra":   {"alex", "lexi", "sandra"},
+			"andrew":      {"andy", "drew"},
+			"anthony":     {"tony"},
+			"benjamin":    {"ben", "benny"},
+			"catherine":   {"cathy", "kate", "cat"},
+			"charles":     {"charlie", "chuck"},
+			"christopher": {"chris", "topher"},
+			"christian":   {"chris"},
+			"christina":   {"chris", "tina"},
+			"christine":   {"chris", "chrissy", "tina"},
+			"daniel":      {"dan", "danny"},
+			"david":       {"dave", "davey"},
+			"deborah":     {"deb", "debbie"},
+			"donald":      {"don", "donnie"},
+			"edward":      {"ed", "eddie", "ted", "ned"},
+			"elizabeth":   {"liz", "beth", "lizzy", "betty", "eliza"},
+			"emily":       {"em", "emmy"},
+			"frederick":   {"fred", "freddie"},
+			"gabriel":     {"gabe"},
+			"gregory":     {"greg"},
+			"jacob":       {"jake"},
+			"james":       {"jim", "jimmy", "jamie"},
+			"jeffrey":     {"jeff"},
+			"jennifer":    {"jen", "jenny"},
+			"jessica":     {"jess", "jessie"},
+			"john":        {"johnny", "jack"},
+			"jonathan":    {"jon", "jonny"},
+			"joseph":      {"joe", "joey"},
+			"joshua":      {"josh"},
+			"katherine":   {"kate", "kathy", "katie", "kat"},
+			"kenneth":     {"ken", "kenny"},
+			"kimberly":    {"kim"},
+			"lawrence":    {"larry"},
+			"margaret":    {"maggie", "meg", "peggy"},
+			"matthew":     {"matt"},
+			"michael":     {"mike", "mikey", "mick"},
+			"nathaniel":   {"nate", "nathan"},
+			"nicholas":    {"nick", "nicky"},
+			"olivia":      {"liv", "livvy"},
+			"patricia":    {"pat", "patty", "trish"},
+			"patrick":     {"pat", "paddy"},
+			"peter":       {"pete"},
+			"raymond":     {"ray"},
+			"rebecca":     {"becky", "becca"},
+			"richard":     {"rick", "rich", "richie", "dick"},
+			"robert":      {"bob", "rob", "bobby", "robbie"},
+			"ronald":      {"ron", "ronnie"},
+			"samantha":    {"sam", "sammy"},
+			"samuel":      {"sam", "sammy"},
+			"stephen":     {"steve"},
+			"steven":      {"steve"},
+			"susan":       {"sue", "susie"},
+			"theodore":    {"ted", "teddy", "theo"},
+			"thomas":      {"tom", "tommy"},
+			"timothy":     {"tim", "timmy"},
+			"victoria":    {"vicky", "tori"},
+			"william":     {"will", "bill", "billy", "liam"},
+			"zachary":     {"zach"},
+		},
+		"de": {
+			"alexander":  {"alex", "sascha"},
+			"alexandra":  {"alex", "sandra"},
+			"andreas":    {"andi", "andy"},
+			"barbara":    {"babsi", "bärbel"},
+			"benjamin":   {"ben", "benni"},
+			"christian":  {"chris", "christl"},
+			"christina":  {"chris", "tina"},
+			"christine":  {"chris", "tine"},
+			"cornelia":   {"conny"},
+			"daniel":     {"dani"},
+			"daniela":    {"dani"},
+			"dominik":    {"domi"},
+			"elisabeth":  {"lisa", "elli", "lisbeth", "sissi"},
+			"ferdinand":  {"ferdi", "ferdl"},
+			"florian":    {"flo"},
+			"franziska":  {"franzi"},
+			"friedrich":  {"fritz"},
+			"gabriele":   {"gabi"},
+			"georg":      {"schorsch"},
+			"heinrich":   {"heinz", "heini"},
+			"helena":     {"lena", "leni"},
+			"joachim":    {"achim", "jo"},
+			"johann":     {"hans"},
+			"johanna":    {"hanna", "jo"},
+			"johannes":   {"hannes", "jo", "johann"},
+			"josef":      {"sepp", "seppi", "jupp"},
+			"katharina":  {"kathi", "kati", "kathrin"},
+			"konstantin": {"konny", "tino"},
+			"leonhard":   {"leo"},
+			"leopold":    {"leo", "poldi"},
+			"lukas":      {"luki"},
+			"magdalena":  {"lena", "leni"},
+			"manuel":     {"manu"},
+			"margarete":  {"grete", "gretel", "margit"},
+			"matthias":   {"matze", "hias"},
+			"maximilian": {"max", "maxi"},
+			"michael":    {"michi", "micha"},
+			"monika":     {"moni"},
+			"nikolaus":   {"klaus", "niko"},
+			"philipp":    {"phil"},
+			"rosemarie":  {"rosi"},
+			"sabine":     {"bine"},
+			"sebastian":  {"basti"},
+			"stephanie":  {"steffi"},
+			"susanne":    {"susi", "sanne"},
+			"theresa":    {"resi"},
+			"thomas":     {"tom", "tommi"},
+			"tobias":     {"tobi"},
+			"ursula":     {"uschi"},
+			"valentin":   {"vali"},
+			"veronika":   {"vroni"},
+			"walter":     {"walti"},
+			"wolfgang":   {"wolf", "wolfi"},
+		},
+	}
+}
+
+// getNameParticles returns the lower-case surname particles dropped by #NoParticle and #SurnameCore
+func getNameParticles() map[string]bool {
+	return map[string]bool{
+		"von": true, "vom": true, "zu": true, "zum": true, "zur": true,
+		"van": true, "der": true, "den": true, "ter": true, "ten": true, "te": true,
+		"de": true, "la": true, "le": true, "les": true, "du": true, "des": true,
+		"del": true, "della": true, "di": true, "da": true, "das": true, "dos": true, "do": true, "y": true,
+	}
+}
+
+// getKeyboardLayouts returns the keyboard layouts usable for keyboard walks. Every layout has the
+// number row and the three letter rows, without the keys left of 1 and right of 0.
+func getKeyboardLayouts() map[string]KeyboardLayout {
+	return map[string]KeyboardLayout{
+		"qwerty": {
+			Rows:    []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"},
+			Shifted: []string{"!@#$%^&*()", "QWERTYUIOP", "ASDFGHJKL", "ZXCVBNM"},
+		},
+		"qwertz": {
+			Rows:    []string{"1234567890", "qwertzuiop", "asdfghjkl", "yxcvbnm"},
+			Shifted: []string{"!\"§$%&/()=", "QWERTZUIOP", "ASDFGHJKL", "YXCVBNM"},
+		},
+		// On AZERTY the digits are the shifted characters of the number row
+		"azerty": {
+			Rows:    []string{"&é\"'(-è_çà", "azertyuiop", "qsdfghjklm", "wxcvbn"},
+			Shifted: []string{"1234567890", "AZERTYUIOP", "QSDFGHJKLM", "WXCVBN"},
+		},
+	}
+}
@@ -0,0 +1,257 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// dateFormats are the date placeholders, matched case-insensitively. Only month and season take a locale.
+var dateFormats = []string{"yy", "yyyy", "m", "mm", "month", "season"}
+
+// dateFormatAliases maps the language-specific placeholders to month or season with a fixed locale
+var dateFormatAliases = map[string][2]string{
+	"monthgerman":    {"month", "de"},
+	"monthenglish":   {"month", "en"},
+	"seasongerman":   {"season", "de"},
+	"seasonamerican": {"season", "en-us"},
+	"seasonbritish":  {"season", "en"},
+}
+
+// DEFAULT_LOCALE is used by {Month} and {Season} if neither the placeholder nor the Locale option sets one
+const DEFAULT_LOCALE = "en"
+
+const (
+	// DATE_SOURCE_NEXT computes dates from the predicted next password change
+	DATE_SOURCE_NEXT = "next"
+	// DATE_SOURCE_NOW uses the current date
+	DATE_SOURCE_NOW = "now"
+)
+
+// dateSourceAttributes maps the lower-case date sources to the attributes they are read from.
+// The empty source is the default used by placeholders without a prefix.
+var dateSourceAttributes = map[string]string{
+	"":                   "pwdLastSet",
+	"pwdlastset":         "pwdLastSet",
+	"whencreated":        "whenCreated",
+	"lastlogontimestamp": "lastLogonTimestamp",
+	"accountexpires":     "accountExpires",
+}
+
+// dateFormatRegex matches format[+-offset], e.g. YYYY-1 or MonthGerman
+var dateFormatRegex = regexp.MustCompile(`^([A-Za-z]+)([+-]\d+)?$`)
+
+// neverExpires is the FILETIME value AD uses for "never"
+const neverExpires = 0x7FFFFFFFFFFFFFFF
+
+// dateSpec is a parsed date placeholder
+type dateSpec struct {
+	source string
+	format string
+	offset int
+	locale string
+}
+
+// parseDatePlaceholder parses a date placeholder of the form [source:]format[+-offset][:locale],
+// e.g. {YYYY}, {MonthEnglish+1}, {whenCreated:YY}, {next:SeasonGerman-1} or {now:Month:fr}
+func parseDatePlaceholder(name string) (dateSpec, bool) {
+	parts := strings.Split(name, ":")
+	var spec dateSpec
+	switch len(parts) {
+	case 1:
+		spec.format = parts[0]
+	case 2:
+		// {source:format} or {format:locale}
+		if isDateSource(parts[0]) {
+			spec.source, spec.format = parts[0], parts[1]
+		} else {
+			spec.format, spec.locale = parts[0], parts[1]
+		}
+	case 3:
+		spec.source, spec.format, spec.locale = parts[0], parts[1], parts[2]
+	default:
+		return dateSpec{}, false
+	}
+	spec.source = strings.ToLower(spec.source)
+	if !isDateSource(spec.source) {
+		return dateSpec{}, false
+	}
+
+	matches := dateFormatRegex.FindStringSubmatch(spec.format)
+	if matches == nil {
+		return dateSpec{}, false
+	}
+	spec.format = strings.ToLower(matches[1])
+	if matches[2] != "" {
+		offset, err := strconv.Atoi(matches[2])
+		if err != nil {
+			return dateSpec{}, false
+		}
+		spec.offset = offset
+	}
+
+	if alias, ok := dateFormatAliases[spec.format]; ok {
+		if spec.locale != "" {
+			return dateSpec{}, false
+		}
+		spec.format, spec.locale = alias[0], alias[1]
+	}
+	known := false
+	for _, format := range dateFormats {
+		if format == spec.format {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return dateSpec{}, false
+	}
+	if spec.locale != "" {
+		if spec.format != "month" && spec.format != "season" {
+			return dateSpec{}, false
+		}
+		if _, _, ok := LookupLocale(spec.locale); !ok {
+			return dateSpec{}, false
+		}
+	}
+	return spec, true
+}
+
+func isDateSource(source string) bool {
+	source = strings.ToLower(source)
+	_, ok := dateSourceAttributes[source]
+	return ok || source == DATE_SOURCE_NEXT || source == DATE_SOURCE_NOW
+}
+
+func isDatePlaceholder(name string) bool {
+	_, ok := parseDatePlaceholder(name)
+	return ok
+}
+
+// resolveDate returns the formatted date of a date placeholder or "" if the date is unknown.
+// Month and season boundaries are computed in the time zone of the Location option.
+func (ctx *maskContext) resolveDate(spec dateSpec) string {
+	var t time.Time
+	var ok bool
+	switch spec.source {
+	case DATE_SOURCE_NOW:
+		t, ok = time.Now(), true
+	case DATE_SOURCE_NEXT:
+		t, ok = ctx.nextPasswordChange(fileTimeToTime(ctx.entry.GetAttributeValue("pwdLastSet")))
+	default:
+		t, ok = attributeToTime(ctx.entry, dateSourceAttributes[spec.source])
+	}
+	if !ok {
+		return ""
+	}
+	if ctx.opts.Location != nil {
+		t = t.In(ctx.opts.Location)
+	}
+
+	locale := spec.locale
+	if locale == "" {
+		locale = ctx.opts.Locale
+	}
+	if locale == "" {
+		locale = DEFAULT_LOCALE
+	}
+	value, _ := convertDate(shiftDate(t, spec.format, spec.offset), spec.format, locale)
+	return value
+}
+
+// attributeToTime reads a FILETIME or, for whenCreated, a GeneralizedTime attribute
+func attributeToTime(entry *ldap.Entry, attribute string) (time.Time, bool) {
+	value := entry.GetAttributeValue(attribute)
+	if attribute == "whenCreated" {
+		return generalizedTimeToTime(value)
+	}
+	return fileTimeToTime(value)
+}
+
+// nextPasswordChange predicts when the user has to change the password next. The computed
+// expiry time is preferred, as it already accounts for PSOs and "password never expires".
+func (ctx *maskContext) nextPasswordChange(pwdLastSet time.Time, pwdLastSetOk bool) (time.Time, bool) {
+	computed := ctx.entry.GetAttributeValue("msDS-UserPasswordExpiryTimeComputed")
+	if computed == strconv.FormatInt(neverExpires, 10) {
+		// The password never expires, pwdLastSet + max. password age would be a made-up date
+		return time.Time{}, false
+	}
+	if expiry, ok := fileTimeToTime(computed); ok {
+		return expiry, true
+	}
+	if !pwdLastSetOk || ctx.policy == nil || ctx.policy.MaxPwdAgeDays <= 0 {
+		return time.Time{}, false
+	}
+	return pwdLastSet.AddDate(0, 0, int(ctx.policy.MaxPwdAgeDays)), true
+}
+
+// shiftDate moves a date by offset units of the format: years for YYYY and YY, seasons for
+// the season placeholders and months otherwise
+func shiftDate(t time.Time, format string, offset int) time.Time {
+	if offset == 0 {
+		return t
+	}
+	// Normalize to the first of the month, otherwise January 31st + 1 month ends up in March
+	t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	switch {
+	case format == "yyyy" || format == "yy":
+		return t.AddDate(offset, 0, 0)
+	case strings.HasPrefix(format, "season"):
+		return t.AddDate(0, 3*offset, 0)
+	default:
+		return t.AddDate(0, offset, 0)
+	}
+}
+
+// generalizedTimeToTime converts an LDAP GeneralizedTime like 20190301101010.0Z to a time
+func generalizedTimeToTime(value string) (time.Time, bool) {
+	if len(value) < 14 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102150405", value[:14], time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// fileTimeToTime converts an AD FILETIME (100ns intervals since 1601-01-01) to a time.
+// ok is false for empty values, 0 and "never".
+func fileTimeToTime(value string) (time.Time, bool) {
+	interval, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || interval <= 0 || interval == neverExpires {
+		return time.Time{}, false
+	}
+	return time.Unix(0, (interval-116444736000000000)*100), true
+}
+
+// convertDate converts a date into the desired format. Month and season names are taken from the locale.
+func convertDate(t time.Time, format string, localeCode string) (string, error) {
+	switch strings.ToLower(format) {
+	case "yyyy":
+		return fmt.Sprintf("%d", t.Year()), nil
+	case "yy":
+		// Get last two numbers of the year
+		year := t.Year() % 100
+		return fmt.Sprintf("%02d", year), nil
+	case "mm":
+		return fmt.Sprintf("%02d", t.Month()), nil
+	case "m":
+		return fmt.Sprintf("%d", t.Month()), nil
+	case "month", "season":
+		locale, south, ok := LookupLocale(localeCode)
+		if !ok {
+			return "", fmt.Errorf("unknown locale %q", localeCode)
+		}
+		if strings.ToLower(format) == "month" {
+			return locale.Months[t.Month()-1], nil
+		}
+		return locale.Seasons[seasonIndex(t.Month(), south)], nil
+	default:
+		return "", fmt.Errorf("unsupported format")
+	}
+}
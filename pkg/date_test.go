@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fileTime converts a time to the FILETIME format of pwdLastSet
+func fileTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/100+116444736000000000, 10)
+}
+
+func TestDatePlaceholders(t *testing.T) {
+	pwdLastSet := time.Date(2024, time.January, 19, 12, 0, 0, 0, time.UTC)
+	policy := &PasswordPolicy{MaxPwdAgeDays: 90}
+	tests := []struct {
+		mask   string
+		expiry string
+		want   []string
+	}{
+		{"{YYYY}{YY}{MM}{M}", "", []string{"202424011"}},
+		{"{Month}{MonthGerman}{Month:fr}", "", []string{"JanuaryJanuarJanvier"}},
+		{"{Season}{SeasonGerman}{SeasonAmerican}{Season:en-south}", "", []string{"WinterWinterWinterSummer"}},
+		{"{YYYY-1}{MonthEnglish+1}{Season-1}", "", []string{"2023FebruaryAutumn"}},
+		{"{next:YYYY}{next:MM}", "", []string{"202404"}},
+		{"{next:YYYY}", fileTime(time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)), []string{"2025"}},
+		// A password that never expires has no next change, pwdLastSet + max. age is not used
+		{"{next:YYYY}", strconv.FormatInt(neverExpires, 10), nil},
+	}
+	for _, tt := range tests {
+		attributes := map[string][]string{"sAMAccountName": {"jdoe"}, "pwdLastSet": {fileTime(pwdLastSet)}}
+		if tt.expiry != "" {
+			attributes["msDS-UserPasswordExpiryTimeComputed"] = []string{tt.expiry}
+		}
+		m, err := CompileMask(tt.mask)
+		if err != nil {
+			t.Errorf("CompileMask(%q): %v", tt.mask, err)
+			continue
+		}
+		opts := GenOptions{OnEmpty: ON_EMPTY_SKIP, Location: time.UTC, Locale: "en", Policies: PolicySet{Domain: policy}}
+		got := generatePWs(ldap.NewEntry("CN=jdoe", attributes), m, opts)
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("generatePWs(%q) = %q, want %q", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestFileTimeToTime(t *testing.T) {
+	want := time.Date(2024, time.January, 19, 12, 0, 0, 0, time.UTC)
+	if got, ok := fileTimeToTime(fileTime(want)); !ok || !got.Equal(want) {
+		t.Errorf("fileTimeToTime = %v, %v, want %v", got, ok, want)
+	}
+	for _, value := range []string{"", "0", "-1", "abc", strconv.FormatInt(neverExpires, 10)} {
+		if _, ok := fileTimeToTime(value); ok {
+			t.Errorf("fileTimeToTime(%q) succeeded, want no time", value)
+		}
+	}
+}
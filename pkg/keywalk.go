@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KEYWALK_PLACEHOLDER generates keyboard walks in masks, e.g. {keywalk:layout=qwertz,length=4-6}
+const KEYWALK_PLACEHOLDER = "keywalk"
+
+// Keyboard walk directions. Down and up walks follow a column and continue with the next column
+// to the right, e.g. 1qay2wsx on QWERTZ.
+const (
+	WALK_RIGHT = "right"
+	WALK_LEFT  = "left"
+	WALK_DOWN  = "down"
+	WALK_UP    = "up"
+	WALK_ALL   = "all"
+)
+
+// Shift usage of keyboard walks
+const (
+	SHIFT_NONE  = "none"
+	SHIFT_FIRST = "first"
+	SHIFT_ALL   = "all"
+)
+
+// KEYWALK_MAX_LENGTH bounds the length of a keyboard walk
+const KEYWALK_MAX_LENGTH = 16
+
+// KeyboardLayout holds the unshifted and shifted characters of the number row and the three letter rows
+type KeyboardLayout struct {
+	Rows    []string
+	Shifted []string
+}
+
+// KeywalkSpec describes which keyboard walks are generated
+type KeywalkSpec struct {
+	Layout     string
+	MinLength  int
+	MaxLength  int
+	Directions []string
+	Shift      string
+}
+
+// ParseKeywalkSpec parses a comma-separated spec like "layout=qwertz,length=4-6,direction=down,shift=first".
+// Defaults are the qwerty layout, length 4, right and down walks and no shift.
+func ParseKeywalkSpec(spec string) (KeywalkSpec, error) {
+	parsed := KeywalkSpec{Layout: "qwerty", MinLength: 4, MaxLength: 4, Directions: []string{WALK_RIGHT, WALK_DOWN}, Shift: SHIFT_NONE}
+	if strings.TrimSpace(spec) == "" {
+		return parsed, nil
+	}
+	for _, option := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(option, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return KeywalkSpec{}, fmt.Errorf("invalid keywalk option %q, expected key=value", option)
+		}
+		switch key {
+		case "layout":
+			if _, ok := getKeyboardLayouts()[value]; !ok {
+				return KeywalkSpec{}, fmt.Errorf("unknown keyboard layout %q, supported: qwerty, qwertz, azerty", value)
+			}
+			parsed.Layout = value
+		case "length":
+			from, to, isRange := strings.Cut(value, "-")
+			minLength, err := strconv.Atoi(from)
+			maxLength := minLength
+			if err == nil && isRange {
+				maxLength, err = strconv.Atoi(to)
+			}
+			if err != nil || minLength < 2 || maxLength < minLength || maxLength > KEYWALK_MAX_LENGTH {
+				return KeywalkSpec{}, fmt.Errorf("invalid keywalk length %q, expected n or n-m between 2 and %d", value, KEYWALK_MAX_LENGTH)
+			}
+			parsed.MinLength, parsed.MaxLength = minLength, maxLength
+		case "direction":
+			switch value {
+			case WALK_RIGHT, WALK_LEFT, WALK_DOWN, WALK_UP:
+				parsed.Directions = []string{value}
+			case WALK_ALL:
+				parsed.Directions = []string{WALK_RIGHT, WALK_DOWN, WALK_LEFT, WALK_UP}
+			default:
+				return KeywalkSpec{}, fmt.Errorf("unknown keywalk direction %q, use right, left, down, up or all", value)
+			}
+		case "shift":
+			switch value {
+			case SHIFT_NONE, SHIFT_FIRST, SHIFT_ALL:
+				parsed.Shift = value
+			default:
+				return KeywalkSpec{}, fmt.Errorf("unknown keywalk shift %q, use none, first or all", value)
+			}
+		default:
+			return KeywalkSpec{}, fmt.Errorf("unknown keywalk option %q, use layout, length, direction and shift", key)
+		}
+	}
+	return parsed, nil
+}
+
+// isKeywalkPlaceholder reports whether a placeholder name is {keywalk} or {keywalk:spec}
+func isKeywalkPlaceholder(name string) bool {
+	prefix, _, _ := strings.Cut(name, ":")
+	return strings.EqualFold(prefix, KEYWALK_PLACEHOLDER)
+}
+
+// keywalkPlaceholderSpec returns the spec of a {keywalk:spec} placeholder
+func keywalkPlaceholderSpec(name string) (KeywalkSpec, error) {
+	_, spec, _ := strings.Cut(name, ":")
+	return ParseKeywalkSpec(spec)
+}
+
+// KeyWalks returns all keyboard walks of a spec, shorter walks first, then by direction and start key
+func KeyWalks(spec KeywalkSpec) []string {
+	layout := getKeyboardLayouts()[spec.Layout]
+	var walks []string
+	for length := spec.MinLength; length <= spec.MaxLength; length++ {
+		for _, direction := range spec.Directions {
+			for row := range layout.Rows {
+				for col := range []rune(layout.Rows[row]) {
+					if walk, ok := layout.walk(row, col, length, direction, spec.Shift); ok {
+						walks = append(walks, walk)
+					}
+				}
+			}
+		}
+	}
+	return uniqueStrings(walks)
+}
+
+// walk returns the walk of the given length starting at a key, ok is false if it leaves the keyboard
+func (l KeyboardLayout) walk(row, col, length int, direction, shift string) (string, bool) {
+	var walk strings.Builder
+	for i := 0; i < length; i++ {
+		keys, shifted := []rune(l.Rows[row]), []rune(l.Shifted[row])
+		if col < 0 || col >= len(keys) {
+			return "", false
+		}
+		if shift == SHIFT_ALL || (shift == SHIFT_FIRST && i == 0) {
+			walk.WriteRune(shifted[col])
+		} else {
+			walk.WriteRune(keys[col])
+		}
+
+		switch direction {
+		case WALK_RIGHT:
+			col++
+		case WALK_LEFT:
+			col--
+		case WALK_DOWN:
+			if row++; row == len(l.Rows) {
+				row, col = 0, col+1
+			}
+		case WALK_UP:
+			if row--; row < 0 {
+				row, col = len(l.Rows)-1, col+1
+			}
+		}
+	}
+	return walk.String(), true
+}
@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestKeyWalks(t *testing.T) {
+	tests := []struct {
+		spec     string
+		contains []string
+		excludes []string
+	}{
+		{"", []string{"1234", "qwer", "1qaz", "asdf"}, []string{"4321", "qwerty"}},
+		{"layout=qwertz,length=6", []string{"qwertz", "yxcvbn"}, []string{"qwerty"}},
+		{"layout=qwertz,length=8,direction=down", []string{"1qay2wsx"}, nil},
+		{"direction=left", []string{"4321", "rewq"}, []string{"1234"}},
+		{"shift=first,length=6", []string{"Qwerty", "!23456"}, []string{"qwerty"}},
+		{"shift=all", []string{"!@#$", "QWER"}, []string{"1234"}},
+		{"layout=azerty,length=4-5", []string{"azer", "azert", "qsdf"}, nil},
+	}
+	for _, tt := range tests {
+		spec, err := ParseKeywalkSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseKeywalkSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		walks := KeyWalks(spec)
+		for _, walk := range tt.contains {
+			if !contains(walks, walk) {
+				t.Errorf("KeyWalks(%q) lacks %q", tt.spec, walk)
+			}
+		}
+		for _, walk := range tt.excludes {
+			if contains(walks, walk) {
+				t.Errorf("KeyWalks(%q) contains %q", tt.spec, walk)
+			}
+		}
+	}
+}
+
+func TestParseKeywalkSpecErrors(t *testing.T) {
+	for _, spec := range []string{"layout=dvorak", "length=1", "length=5-3", "length=17", "direction=diagonal", "shift=some", "size=4", "length"} {
+		if _, err := ParseKeywalkSpec(spec); err == nil {
+			t.Errorf("ParseKeywalkSpec(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
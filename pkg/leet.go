@@ -0,0 +1,292 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// LEET_MAX_VARIANTS bounds the number of candidates a single leet modifier emits per value
+const LEET_MAX_VARIANTS = 256
+
+// LeetTable maps lower-case characters to one or more replacements
+type LeetTable map[rune][]string
+
+// customLeetTables holds the tables loaded with LoadLeetFile by lower-case name
+var customLeetTables = map[string]LeetTable{}
+
+// LoadLeetFile loads a leet table from a file with one rule like "a>4,@" per line and
+// registers it under the file name without extension. Empty lines and lines starting with # are ignored.
+func LoadLeetFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading leet file: %v", err)
+	}
+	defer f.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading leet file: %v", err)
+	}
+
+	table, err := ParseLeetTable(strings.Join(rules, ";"))
+	if err != nil {
+		return "", fmt.Errorf("leet file %s: %v", path, err)
+	}
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if name == "" || strings.ContainsAny(name, ">,;()") {
+		return "", fmt.Errorf("leet file %s: invalid table name %q", path, name)
+	}
+	customLeetTables[name] = table
+	return name, nil
+}
+
+// ParseLeetTable parses an inline table like "a>4,@;e>3;s>$,5". Rules are separated by ;
+// and replacements by , while a backslash escapes the next character.
+func ParseLeetTable(rules string) (LeetTable, error) {
+	table := LeetTable{}
+	for _, rule := range splitEscaped(rules, ';') {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		fromTo := splitEscaped(rule, '>')
+		if len(fromTo) != 2 {
+			return nil, fmt.Errorf("invalid leet rule '%s', expected 'from>to[,to...]'", rule)
+		}
+		from := []rune(unescapeLeet(strings.TrimSpace(fromTo[0])))
+		if len(from) != 1 {
+			return nil, fmt.Errorf("invalid leet rule '%s', 'from' must be a single character", rule)
+		}
+		char := unicode.ToLower(from[0])
+		for _, to := range splitEscaped(fromTo[1], ',') {
+			to = unescapeLeet(strings.TrimSpace(to))
+			if to == "" {
+				return nil, fmt.Errorf("invalid leet rule '%s', empty replacement", rule)
+			}
+			table[char] = appendUnique(table[char], to)
+		}
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("empty leet table")
+	}
+	return table, nil
+}
+
+// lookupLeetTable returns an inline table if the argument contains a rule, otherwise the
+// built-in table basic or basicplus or a table loaded with LoadLeetFile
+func lookupLeetTable(arg string) (LeetTable, error) {
+	if strings.Contains(arg, ">") {
+		return ParseLeetTable(arg)
+	}
+	name := strings.ToLower(strings.TrimSpace(arg))
+	if table, ok := customLeetTables[name]; ok {
+		return table, nil
+	}
+	switch name {
+	case "basic":
+		return leetTableFromMap(getLeetBasic()), nil
+	case "basicplus":
+		return leetTableFromMap(getLeetBasicPlus()), nil
+	}
+	return nil, fmt.Errorf("unknown leet table %q, supported: %s or an inline table like a>4,@;e>3", arg, strings.Join(supportedLeetTables(), ", "))
+}
+
+// parseLeetPermArgs splits the arguments of #LeetPerm into the table and the optional
+// leading maximum number of substitutions, e.g. "2,a>4;e>3"
+func parseLeetPermArgs(args string) (string, int, error) {
+	if i := strings.IndexByte(args, ','); i > 0 && !strings.Contains(args[:i], ">") {
+		if n, err := strconv.Atoi(strings.TrimSpace(args[:i])); err == nil {
+			if n < 1 {
+				return "", 0, fmt.Errorf("the maximum number of substitutions must be >= 1")
+			}
+			return args[i+1:], n, nil
+		}
+	}
+	return args, 0, nil
+}
+
+func supportedLeetTables() []string {
+	names := []string{"basic", "basicplus"}
+	for name := range customLeetTables {
+		if name != "basic" && name != "basicplus" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[2:])
+	return names
+}
+
+func leetTableFromMap(leetMap map[rune]string) LeetTable {
+	table := LeetTable{}
+	for char, to := range leetMap {
+		table[unicode.ToLower(char)] = []string{to}
+	}
+	return table
+}
+
+// leetReplacements returns the replacements of a character, upper-cased for upper-case characters
+func (t LeetTable) leetReplacements(char rune) []string {
+	replacements := t[unicode.ToLower(char)]
+	if !unicode.IsUpper(char) {
+		return replacements
+	}
+	upper := make([]string, len(replacements))
+	for i, to := range replacements {
+		upper[i] = strings.ToUpper(to)
+	}
+	return upper
+}
+
+// leetFull substitutes every mapped character. Characters with several replacements emit one
+// candidate per replacement, the same replacement is used for all occurrences of a character.
+func leetFull(value string, table LeetTable) []string {
+	var chars []rune
+	seen := map[rune]bool{}
+	for _, char := range value {
+		if _, ok := table[unicode.ToLower(char)]; ok && !seen[unicode.ToLower(char)] {
+			seen[unicode.ToLower(char)] = true
+			chars = append(chars, unicode.ToLower(char))
+		}
+	}
+
+	choices := []map[rune]int{{}}
+	for _, char := range chars {
+		var next []map[rune]int
+		for _, choice := range choices {
+			for i := range table[char] {
+				if len(next) == LEET_MAX_VARIANTS {
+					break
+				}
+				extended := map[rune]int{char: i}
+				for c, j := range choice {
+					extended[c] = j
+				}
+				next = append(next, extended)
+			}
+		}
+		choices = next
+	}
+
+	results := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		var result strings.Builder
+		for _, char := range value {
+			if i, ok := choice[unicode.ToLower(char)]; ok {
+				result.WriteString(table.leetReplacements(char)[i])
+			} else {
+				result.WriteRune(char)
+			}
+		}
+		results = append(results, result.String())
+	}
+	return uniqueStrings(results)
+}
+
+// leetVariants emits the partial substitutions of a value ordered by the number of substituted
+// characters, e.g. P4ssword and Passw0rd before P4ssw0rd. maxSubs limits the substitutions
+// per candidate (0 for no limit), the total is capped at LEET_MAX_VARIANTS.
+func leetVariants(value string, table LeetTable, maxSubs int) []string {
+	runes := []rune(value)
+	var positions []int
+	for i, char := range runes {
+		if _, ok := table[unicode.ToLower(char)]; ok {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) == 0 {
+		return []string{value}
+	}
+	if maxSubs <= 0 || maxSubs > len(positions) {
+		maxSubs = len(positions)
+	}
+
+	// Every character is a segment, so replacements of any length keep the positions intact
+	segments := make([]string, len(runes))
+	for i, char := range runes {
+		segments[i] = string(char)
+	}
+
+	var results []string
+	var substitute func(start, left int)
+	substitute = func(start, left int) {
+		if left == 0 {
+			results = append(results, strings.Join(segments, ""))
+			return
+		}
+		for p := start; p < len(positions) && len(results) < LEET_MAX_VARIANTS; p++ {
+			pos := positions[p]
+			for _, to := range table.leetReplacements(runes[pos]) {
+				if len(results) >= LEET_MAX_VARIANTS {
+					break
+				}
+				segments[pos] = to
+				substitute(p+1, left-1)
+			}
+			segments[pos] = string(runes[pos])
+		}
+	}
+	for count := 1; count <= maxSubs && len(results) < LEET_MAX_VARIANTS; count++ {
+		substitute(0, count)
+	}
+	return uniqueStrings(results)
+}
+
+// splitEscaped splits s at unescaped separators and keeps the escapes for the caller
+func splitEscaped(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			current.WriteRune(r)
+			escaped = true
+		case r == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
+
+// unescapeLeet removes the backslash of escaped characters like \; \, \> or \)
+func unescapeLeet(s string) string {
+	var result strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LOCALE_SOUTH_SUFFIX can be appended to every locale code to use southern-hemisphere seasons
+const LOCALE_SOUTH_SUFFIX = "-south"
+
+// Locale holds the month and season names of a language. Seasons are ordered spring, summer, autumn, winter.
+type Locale struct {
+	Code    string   `json:"code"`
+	Months  []string `json:"months"`
+	Seasons []string `json:"seasons"`
+}
+
+// customLocales holds the locales loaded with LoadLocaleFile
+var customLocales = map[string]Locale{}
+
+// LoadLocaleFile loads a custom locale from a JSON file like
+// {"code": "xx", "months": ["..." x12], "seasons": ["spring", "summer", "autumn", "winter"]}.
+// A custom locale overrides a built-in locale with the same code.
+func LoadLocaleFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading locale file: %v", err)
+	}
+
+	var locale Locale
+	if err := json.Unmarshal(data, &locale); err != nil {
+		return "", fmt.Errorf("error unmarshalling locale file %s: %v", path, err)
+	}
+	locale.Code = strings.ToLower(strings.TrimSpace(locale.Code))
+	if locale.Code == "" || strings.ContainsAny(locale.Code, ":{}|#") || strings.HasSuffix(locale.Code, LOCALE_SOUTH_SUFFIX) {
+		return "", fmt.Errorf("locale file %s: invalid code %q", path, locale.Code)
+	}
+	if len(locale.Months) != 12 {
+		return "", fmt.Errorf("locale file %s: expected 12 months, got %d", path, len(locale.Months))
+	}
+	if len(locale.Seasons) != 4 {
+		return "", fmt.Errorf("locale file %s: expected 4 seasons, got %d", path, len(locale.Seasons))
+	}
+
+	customLocales[locale.Code] = locale
+	return locale.Code, nil
+}
+
+// LookupLocale returns the locale for a code like "fr" or "es-south" and whether
+// southern-hemisphere seasons should be used
+func LookupLocale(code string) (Locale, bool, bool) {
+	code = strings.ToLower(code)
+	south := strings.HasSuffix(code, LOCALE_SOUTH_SUFFIX)
+	code = strings.TrimSuffix(code, LOCALE_SOUTH_SUFFIX)
+
+	if locale, ok := customLocales[code]; ok {
+		return locale, south, true
+	}
+	locale, ok := getBuiltinLocales()[code]
+	return locale, south, ok
+}
+
+// SupportedLocales returns the sorted codes of all built-in and custom locales
+func SupportedLocales() []string {
+	seen := map[string]bool{}
+	for code := range getBuiltinLocales() {
+		seen[code] = true
+	}
+	for code := range customLocales {
+		seen[code] = true
+	}
+
+	codes := make([]string, 0, len(seen))
+	for code := range seen {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// seasonIndex returns SPRING, SUMMER, AUTUMN or WINTER for a month. On the southern
+// hemisphere the seasons are shifted by half a year.
+func seasonIndex(month time.Month, south bool) int {
+	if south {
+		month = (month+5)%12 + 1
+	}
+	switch month {
+	case time.March, time.April, time.May:
+		return SPRING
+	case time.June, time.July, time.August:
+		return SUMMER
+	case time.September, time.October, time.November:
+		return AUTUMN
+	default:
+		return WINTER
+	}
+}
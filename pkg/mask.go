@@ -0,0 +1,665 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Mask is the parsed form of a password mask
+type Mask struct {
+	Raw   string
+	Nodes []MaskNode
+	// Weight ranks the candidates of this mask against the ones of other masks, 1 if not set
+	Weight float64
+	// Template is set for text/template masks instead of Nodes
+	Template *template.Template
+	// templateFailed is set once a template failed for a user, so the error is only shown once
+	templateFailed bool
+	// modifierFailed is set once a modifier failed for a value, so the error is only shown once
+	modifierFailed bool
+}
+
+// MaskNode is a single element of a parsed mask
+type MaskNode interface {
+	Column() int
+}
+
+// LiteralNode is text that is copied verbatim into the password
+type LiteralNode struct {
+	Text string
+	Col  int
+}
+
+// GroupNode is a (a|b) alternation. Every alternative is a sequence of nodes on its own.
+type GroupNode struct {
+	Alternatives [][]MaskNode
+	Col          int
+}
+
+// PlaceholderNode is a {name|name2#Modifier...} expression. Every alternative yields its own candidate.
+type PlaceholderNode struct {
+	Alternatives []PlaceholderAlt
+	Modifiers    []ModifierCall
+	Col          int
+}
+
+// PlaceholderAlt is a single alternative of a placeholder. Its value is the first non-empty
+// value of the fallback chain {a?b?c}, or the literal default {a:-text} if all of them are empty.
+type PlaceholderAlt struct {
+	Chain      []PlaceholderRef
+	Default    string
+	HasDefault bool
+}
+
+// PlaceholderRef references a single attribute or date placeholder
+type PlaceholderRef struct {
+	Name string
+	Col  int
+}
+
+// DirectiveNode is a {!name:mode(arg)} expression that controls how the password is built,
+// e.g. {!fit:digits} fills the password up to the minimum length of the user's policy
+type DirectiveNode struct {
+	Name   string
+	Mode   string
+	Arg    string
+	HasArg bool
+	Col    int
+}
+
+// CharsetNode is a hashcat-style ?d, ?l, ?u, ?s, ?a, ?h, ?H or custom ?1-?4 token that expands to every character of its charset
+type CharsetNode struct {
+	Charset rune
+	Col     int
+}
+
+// ModifierCall is a single #Modifier or #Modifier(args) applied to a placeholder
+type ModifierCall struct {
+	Name    string
+	Args    string
+	HasArgs bool
+	Col     int
+}
+
+func (n *LiteralNode) Column() int     { return n.Col }
+func (n *GroupNode) Column() int       { return n.Col }
+func (n *PlaceholderNode) Column() int { return n.Col }
+func (n *DirectiveNode) Column() int   { return n.Col }
+func (n *CharsetNode) Column() int     { return n.Col }
+
+// MaskError describes a syntax or validation error at a 1-based rune column of a mask
+type MaskError struct {
+	Mask   string
+	Column int
+	Msg    string
+}
+
+func (e *MaskError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// Pointer returns the mask followed by a line with a caret under the offending column
+func (e *MaskError) Pointer() string {
+	col := e.Column
+	if col < 1 {
+		col = 1
+	}
+	return fmt.Sprintf("%s\n%s^", e.Mask, strings.Repeat(" ", col-1))
+}
+
+// literalEscapes are the characters that may be escaped with a backslash outside of placeholders
+const literalEscapes = "{}()|?\\"
+
+// EscapeMaskLiteral escapes the characters that have a meaning in masks, so that text is copied verbatim
+func EscapeMaskLiteral(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(literalEscapes, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// EscapeMaskWord escapes text like EscapeMaskLiteral but keeps {placeholders}, for word lists that
+// mix literal text and placeholders like {sn}. A '{' without a closing '}' is escaped.
+func EscapeMaskWord(text string) string {
+	var escaped strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '{' {
+			if end := placeholderEnd(runes, i); end > 0 {
+				escaped.WriteString(string(runes[i : end+1]))
+				i = end
+				continue
+			}
+		}
+		if strings.ContainsRune(literalEscapes, runes[i]) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(runes[i])
+	}
+	return escaped.String()
+}
+
+// placeholderEnd returns the index of the '}' that closes the placeholder opened at start, -1 if there is none
+func placeholderEnd(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '{':
+			return -1
+		case '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultEscapes are the characters that may be escaped with a backslash in a {name:-default}
+const defaultEscapes = "{}|#\\"
+
+type maskParser struct {
+	raw string
+	src []rune
+	pos int
+}
+
+func (p *maskParser) errorf(pos int, format string, args ...interface{}) *MaskError {
+	return &MaskError{Mask: p.raw, Column: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *maskParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+// ParseMask parses a mask into its AST. It only checks the syntax, use ValidateMask
+// to check placeholder and modifier names.
+func ParseMask(mask string) (*Mask, error) {
+	p := &maskParser{raw: mask, src: []rune(mask)}
+	nodes, err := p.parseSequence(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Mask{Raw: mask, Nodes: nodes}, nil
+}
+
+// parseSequence parses literals, placeholders and groups until the end of the mask or,
+// inside a group, until the '|' or ')' that ends the current alternative
+func (p *maskParser) parseSequence(depth int) ([]MaskNode, error) {
+	var nodes []MaskNode
+	var literal strings.Builder
+	literalStart := 0
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			nodes = append(nodes, &LiteralNode{Text: literal.String(), Col: literalStart + 1})
+			literal.Reset()
+		}
+	}
+
+	for !p.eof() {
+		char := p.src[p.pos]
+		if literal.Len() == 0 {
+			literalStart = p.pos
+		}
+
+		switch char {
+		case '\\':
+			// A backslash only escapes mask syntax, otherwise it is a literal backslash
+			if p.pos+1 < len(p.src) && strings.ContainsRune(literalEscapes, p.src[p.pos+1]) {
+				literal.WriteRune(p.src[p.pos+1])
+				p.pos += 2
+				continue
+			}
+			literal.WriteRune(char)
+			p.pos++
+		case '{':
+			flushLiteral()
+			var node MaskNode
+			var err error
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] == '!' {
+				node, err = p.parseDirective()
+			} else {
+				node, err = p.parsePlaceholder()
+			}
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
+		case '(':
+			flushLiteral()
+			group, err := p.parseGroup(depth)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, group...)
+		case ')', '|':
+			if depth == 0 {
+				if char == ')' {
+					return nil, p.errorf(p.pos, "unexpected ')' without matching '(' (escape it as \\))")
+				}
+				return nil, p.errorf(p.pos, "unexpected '|' outside of a group, use (a|b) or escape it as \\|")
+			}
+			flushLiteral()
+			return nodes, nil
+		case '?':
+			// ?? is a literal '?' like in hashcat, a '?' that is not followed by a charset stays literal
+			next := rune(0)
+			if p.pos+1 < len(p.src) {
+				next = p.src[p.pos+1]
+			}
+			switch {
+			case next == '?':
+				literal.WriteRune(char)
+				p.pos += 2
+			case isCharsetID(next):
+				flushLiteral()
+				nodes = append(nodes, &CharsetNode{Charset: next, Col: p.pos + 1})
+				p.pos += 2
+			default:
+				literal.WriteRune(char)
+				p.pos++
+			}
+		case '}':
+			return nil, p.errorf(p.pos, "unexpected '}' without matching '{' (escape it as \\})")
+		default:
+			literal.WriteRune(char)
+			p.pos++
+		}
+	}
+	flushLiteral()
+
+	return nodes, nil
+}
+
+// parseGroup parses (alt1|alt2|...) starting at the opening parenthesis. Parentheses without a '|'
+// are literal text, so masks like Foo(1)! keep their parentheses.
+func (p *maskParser) parseGroup(depth int) ([]MaskNode, error) {
+	open := p.pos
+	p.pos++
+
+	node := &GroupNode{Col: open + 1}
+	for {
+		alternative, err := p.parseSequence(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf(open, "unclosed '('")
+		}
+		node.Alternatives = append(node.Alternatives, alternative)
+
+		char := p.src[p.pos]
+		p.pos++
+		if char != ')' {
+			continue
+		}
+		if len(node.Alternatives) == 1 {
+			nodes := append([]MaskNode{&LiteralNode{Text: "(", Col: open + 1}}, alternative...)
+			return append(nodes, &LiteralNode{Text: ")", Col: p.pos}), nil
+		}
+		return []MaskNode{node}, nil
+	}
+}
+
+// parsePlaceholder parses {name#Mod1#Mod2(args)} starting at the opening brace
+func (p *maskParser) parsePlaceholder() (*PlaceholderNode, error) {
+	open := p.pos
+	p.pos++
+
+	node := &PlaceholderNode{Col: open + 1}
+	var alternative PlaceholderAlt
+	var name strings.Builder
+	nameStart := p.pos
+	addRef := func() error {
+		if strings.TrimSpace(name.String()) == "" {
+			return p.errorf(nameStart, "empty placeholder name")
+		}
+		alternative.Chain = append(alternative.Chain, PlaceholderRef{Name: name.String(), Col: nameStart + 1})
+		name.Reset()
+		return nil
+	}
+
+	for !p.eof() && p.src[p.pos] != '#' && p.src[p.pos] != '}' {
+		switch char := p.src[p.pos]; {
+		case char == '{':
+			return nil, p.errorf(p.pos, "nested '{' inside placeholder opened at column %d", open+1)
+		case char == '|':
+			if !alternative.HasDefault {
+				if err := addRef(); err != nil {
+					return nil, err
+				}
+			}
+			node.Alternatives = append(node.Alternatives, alternative)
+			alternative = PlaceholderAlt{}
+			nameStart = p.pos + 1
+		case char == '?':
+			if err := addRef(); err != nil {
+				return nil, err
+			}
+			nameStart = p.pos + 1
+		case char == ':' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '-':
+			if err := addRef(); err != nil {
+				return nil, err
+			}
+			p.pos += 2
+			alternative.Default = p.parseDefault()
+			alternative.HasDefault = true
+			continue
+		default:
+			name.WriteRune(char)
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return nil, p.errorf(open, "unclosed '{'")
+	}
+	if !alternative.HasDefault {
+		if err := addRef(); err != nil {
+			return nil, err
+		}
+	}
+	node.Alternatives = append(node.Alternatives, alternative)
+
+	for p.src[p.pos] == '#' {
+		modifier, err := p.parseModifier(open)
+		if err != nil {
+			return nil, err
+		}
+		node.Modifiers = append(node.Modifiers, modifier)
+		if p.eof() {
+			return nil, p.errorf(open, "unclosed '{'")
+		}
+	}
+	if p.src[p.pos] != '}' {
+		return nil, p.errorf(p.pos, "unexpected %q in placeholder, expected '#' or '}'", p.src[p.pos])
+	}
+	p.pos++
+
+	return node, nil
+}
+
+// parseDirective parses {!name}, {!name:mode} or {!name:mode(arg)} starting at the opening brace
+func (p *maskParser) parseDirective() (*DirectiveNode, error) {
+	open := p.pos
+	p.pos += 2
+
+	node := &DirectiveNode{Col: open + 1}
+	readName := func() string {
+		var name strings.Builder
+		for !p.eof() && isModifierNameRune(p.src[p.pos]) {
+			name.WriteRune(p.src[p.pos])
+			p.pos++
+		}
+		return name.String()
+	}
+
+	node.Name = readName()
+	if node.Name == "" {
+		return nil, p.errorf(p.pos, "missing directive name after '{!'")
+	}
+	if !p.eof() && p.src[p.pos] == ':' {
+		p.pos++
+		node.Mode = readName()
+		if node.Mode == "" {
+			return nil, p.errorf(p.pos, "missing mode after ':' in directive %s", node.Name)
+		}
+		if !p.eof() && p.src[p.pos] == '(' {
+			parenOpen := p.pos
+			p.pos++
+			var arg strings.Builder
+			for !p.eof() && p.src[p.pos] != ')' {
+				if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) {
+					p.pos++
+				}
+				arg.WriteRune(p.src[p.pos])
+				p.pos++
+			}
+			if p.eof() {
+				return nil, p.errorf(parenOpen, "unclosed '(' in directive %s", node.Name)
+			}
+			p.pos++
+			node.Arg = arg.String()
+			node.HasArg = true
+		}
+	}
+	if p.eof() {
+		return nil, p.errorf(open, "unclosed '{'")
+	}
+	if p.src[p.pos] != '}' {
+		return nil, p.errorf(p.pos, "unexpected %q in directive, expected '}'", p.src[p.pos])
+	}
+	p.pos++
+
+	return node, nil
+}
+
+// parseDefault reads the literal default of {name:-default} up to the next unescaped '|', '#' or '}'
+func (p *maskParser) parseDefault() string {
+	var value strings.Builder
+	for !p.eof() {
+		char := p.src[p.pos]
+		if char == '\\' && p.pos+1 < len(p.src) && strings.ContainsRune(defaultEscapes, p.src[p.pos+1]) {
+			value.WriteRune(p.src[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		if char == '|' || char == '#' || char == '}' || char == '{' {
+			break
+		}
+		value.WriteRune(char)
+		p.pos++
+	}
+	return value.String()
+}
+
+// parseModifier parses #Name or #Name(args) starting at the '#'. The arguments are kept
+// raw including escape sequences, so that every modifier can apply its own escaping rules.
+func (p *maskParser) parseModifier(open int) (ModifierCall, error) {
+	hash := p.pos
+	p.pos++
+
+	var name strings.Builder
+	for !p.eof() && isModifierNameRune(p.src[p.pos]) {
+		name.WriteRune(p.src[p.pos])
+		p.pos++
+	}
+	if name.Len() == 0 {
+		if p.eof() {
+			return ModifierCall{}, p.errorf(open, "unclosed '{'")
+		}
+		return ModifierCall{}, p.errorf(p.pos, "missing modifier name after '#'")
+	}
+
+	call := ModifierCall{Name: name.String(), Col: hash + 1}
+	if p.eof() || p.src[p.pos] != '(' {
+		return call, nil
+	}
+
+	parenOpen := p.pos
+	p.pos++
+	depth := 1
+	var args strings.Builder
+	for !p.eof() {
+		char := p.src[p.pos]
+		if char == '\\' && p.pos+1 < len(p.src) {
+			args.WriteRune(char)
+			args.WriteRune(p.src[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		if char == '(' {
+			depth++
+		} else if char == ')' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+		args.WriteRune(char)
+		p.pos++
+	}
+	if p.eof() {
+		return ModifierCall{}, p.errorf(parenOpen, "unclosed '(' in modifier %s", call.Name)
+	}
+	p.pos++
+
+	call.Args = args.String()
+	call.HasArgs = true
+	return call, nil
+}
+
+func isModifierNameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// ValidateMask checks that all placeholders and modifiers of a parsed mask are known
+// and that their arguments are valid
+func ValidateMask(m *Mask) error {
+	if err := validateDirectives(m); err != nil {
+		return err
+	}
+	if err := validateCharsets(m); err != nil {
+		return err
+	}
+
+	var err error
+	walkPlaceholders(m.Nodes, func(placeholder *PlaceholderNode) bool {
+		for _, alternative := range placeholder.Alternatives {
+			for _, ref := range alternative.Chain {
+				if isKeywalkPlaceholder(ref.Name) {
+					if _, specErr := keywalkPlaceholderSpec(ref.Name); specErr != nil {
+						err = &MaskError{Mask: m.Raw, Column: ref.Col, Msg: specErr.Error()}
+						return false
+					}
+					continue
+				}
+				if !isKnownPlaceholder(ref.Name) {
+					msg := fmt.Sprintf("unknown placeholder %q", ref.Name)
+					if strings.Contains(ref.Name, ":") {
+						msg += fmt.Sprintf(", expected [source:]format[+-n][:locale] with locales %s", strings.Join(SupportedLocales(), ", "))
+					}
+					err = &MaskError{Mask: m.Raw, Column: ref.Col, Msg: msg}
+					return false
+				}
+			}
+		}
+		for i, modifier := range placeholder.Modifiers {
+			if modifier.Name == EACH_MODIFIER && i > 0 {
+				err = &MaskError{Mask: m.Raw, Column: modifier.Col, Msg: "#Each must be the first modifier"}
+				return false
+			}
+			if modifierErr := validateModifier(modifier); modifierErr != nil {
+				err = &MaskError{Mask: m.Raw, Column: modifier.Col, Msg: modifierErr.Error()}
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// validateDirectives checks all {!...} directives of a mask
+func validateDirectives(m *Mask) error {
+	var directives []*DirectiveNode
+	walkNodes(m.Nodes, func(node MaskNode) {
+		if directive, ok := node.(*DirectiveNode); ok {
+			directives = append(directives, directive)
+		}
+	})
+
+	fits := 0
+	for _, directive := range directives {
+		if directive.Name != "fit" {
+			return &MaskError{Mask: m.Raw, Column: directive.Col, Msg: fmt.Sprintf("unknown directive %q", directive.Name)}
+		}
+		if fits++; fits > 1 {
+			return &MaskError{Mask: m.Raw, Column: directive.Col, Msg: "only one {!fit} directive is allowed per mask"}
+		}
+		switch directive.Mode {
+		case "", FIT_DIGITS:
+			if directive.HasArg {
+				return &MaskError{Mask: m.Raw, Column: directive.Col, Msg: "{!fit:digits} does not take an argument"}
+			}
+		case FIT_REPEAT:
+			if directive.Arg == "" {
+				return &MaskError{Mask: m.Raw, Column: directive.Col, Msg: "{!fit:repeat(x)} requires a non-empty string to repeat"}
+			}
+		default:
+			return &MaskError{Mask: m.Raw, Column: directive.Col, Msg: fmt.Sprintf("unknown fit mode %q, use digits or repeat(x)", directive.Mode)}
+		}
+	}
+	return nil
+}
+
+// walkNodes calls fn for every node, including the ones nested in groups
+func walkNodes(nodes []MaskNode, fn func(MaskNode)) {
+	for _, node := range nodes {
+		fn(node)
+		if group, ok := node.(*GroupNode); ok {
+			for _, alternative := range group.Alternatives {
+				walkNodes(alternative, fn)
+			}
+		}
+	}
+}
+
+// walkPlaceholders calls fn for every placeholder, including the ones nested in groups,
+// until fn returns false
+func walkPlaceholders(nodes []MaskNode, fn func(*PlaceholderNode) bool) bool {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *PlaceholderNode:
+			if !fn(n) {
+				return false
+			}
+		case *GroupNode:
+			for _, alternative := range n.Alternatives {
+				if !walkPlaceholders(alternative, fn) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// CompileMask parses and validates a mask. Masks starting with tmpl: are parsed as text/template.
+func CompileMask(mask string) (*Mask, error) {
+	if isTemplateMask(mask) {
+		return parseTemplateMask(mask, "mask", strings.TrimPrefix(mask, TEMPLATE_PREFIX))
+	}
+	m, err := ParseMask(mask)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateMask(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// isKnownPlaceholder reports whether a placeholder is a date placeholder, a variable, a registered
+// placeholder or a queried LDAP attribute. Whether a variable is defined is checked by CheckVariables.
+func isKnownPlaceholder(name string) bool {
+	if isDatePlaceholder(name) || isVariablePlaceholder(name) {
+		return true
+	}
+	if _, ok := lookupPlaceholder(name); ok {
+		return true
+	}
+	return isAttribute(name)
+}
+
+// isAttribute reports whether name is one of the queried LDAP attributes
+func isAttribute(name string) bool {
+	for _, attribute := range UserAttributes {
+		if strings.EqualFold(attribute, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func testEntry() *ldap.Entry {
+	return ldap.NewEntry("CN=John Doe,DC=corp,DC=local", map[string][]string{
+		"sAMAccountName": {"jdoe"},
+		"givenName":      {"John"},
+		"sn":             {"Doe"},
+		"displayName":    {"John Doe-Smith"},
+		"proxyAddresses": {"SMTP:john.doe@corp.local", "smtp:jd@corp.local"},
+	})
+}
+
+func TestParseMaskErrors(t *testing.T) {
+	tests := []struct {
+		mask   string
+		column int
+		msg    string
+	}{
+		{"Foo{givenName", 4, "unclosed"},
+		{"a)b", 2, "unexpected ')'"},
+		{"a|b", 2, "unexpected '|'"},
+		{"x(a|b", 2, "unclosed '('"},
+		{"x}", 2, "unexpected '}'"},
+		{"{sn#Upper(}", 10, "unclosed '('"},
+	}
+	for _, tt := range tests {
+		_, err := ParseMask(tt.mask)
+		var maskErr *MaskError
+		if !errors.As(err, &maskErr) {
+			t.Errorf("ParseMask(%q) error = %v, want a MaskError", tt.mask, err)
+			continue
+		}
+		if maskErr.Column != tt.column || !strings.Contains(maskErr.Msg, tt.msg) {
+			t.Errorf("ParseMask(%q) = column %d %q, want column %d containing %q", tt.mask, maskErr.Column, maskErr.Msg, tt.column, tt.msg)
+		}
+	}
+}
+
+func TestCompileMaskErrors(t *testing.T) {
+	tests := []struct {
+		mask   string
+		column int
+		msg    string
+	}{
+		{"x{foo}", 3, "unknown placeholder"},
+		{"{sn#Nope}", 4, "unknown modifier"},
+		{"{sn#Upper#Each}", 10, "#Each must be the first modifier"},
+		{"{sn#First(x)}", 4, ""},
+		{"{keywalk:layout=dvorak}", 2, "unknown keyboard layout"},
+	}
+	for _, tt := range tests {
+		_, err := CompileMask(tt.mask)
+		var maskErr *MaskError
+		if !errors.As(err, &maskErr) {
+			t.Errorf("CompileMask(%q) error = %v, want a MaskError", tt.mask, err)
+			continue
+		}
+		if maskErr.Column != tt.column || !strings.Contains(maskErr.Msg, tt.msg) {
+			t.Errorf("CompileMask(%q) = column %d %q, want column %d containing %q", tt.mask, maskErr.Column, maskErr.Msg, tt.column, tt.msg)
+		}
+	}
+}
+
+func TestGeneratePWs(t *testing.T) {
+	tests := []struct {
+		mask    string
+		onEmpty string
+		want    []string
+	}{
+		{"{givenName|sn}!", ON_EMPTY_SKIP, []string{"John!", "Doe!"}},
+		{"(Sommer|Winter)1", ON_EMPTY_SKIP, []string{"Sommer1", "Winter1"}},
+		{"Foo(1)!{givenName}", ON_EMPTY_SKIP, []string{"Foo(1)!John"}},
+		{`\(a\|b\)\{c\}`, ON_EMPTY_SKIP, []string{"(a|b){c}"}},
+		{"a?b??", ON_EMPTY_SKIP, []string{"a?b?"}},
+		{"{department:-Corp}", ON_EMPTY_SKIP, []string{"Corp"}},
+		{"{department?sn}", ON_EMPTY_SKIP, []string{"Doe"}},
+		{"{department}x", ON_EMPTY_SKIP, []string{}},
+		{"{department}x", ON_EMPTY_KEEP, []string{"x"}},
+		{"{department}x", ON_EMPTY_FALLBACK, []string{"jdoex"}},
+		{"{proxyAddresses}", ON_EMPTY_SKIP, []string{"SMTP:john.doe@corp.local"}},
+		{"{proxyAddresses#Each#Extract(^(?i)smtp:([^@]+))}", ON_EMPTY_SKIP, []string{"john.doe", "jd"}},
+		{"{sn#Upper#Reverse}", ON_EMPTY_SKIP, []string{"EOD"}},
+		{"{sn#ToggleFirst}", ON_EMPTY_SKIP, []string{"Doe", "doe"}},
+	}
+	for _, tt := range tests {
+		m, err := CompileMask(tt.mask)
+		if err != nil {
+			t.Errorf("CompileMask(%q): %v", tt.mask, err)
+			continue
+		}
+		got := generatePWs(testEntry(), m, GenOptions{OnEmpty: tt.onEmpty})
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("generatePWs(%q, %s) = %q, want %q", tt.mask, tt.onEmpty, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratePWsCharsets(t *testing.T) {
+	m, err := CompileMask("A?d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := generatePWs(testEntry(), m, GenOptions{OnEmpty: ON_EMPTY_SKIP})
+	if len(got) != 10 || got[0] != "A0" || got[9] != "A9" {
+		t.Errorf("generatePWs(A?d) = %q, want A0 to A9", got)
+	}
+}
+
+func TestGeneratePWsFailingModifier(t *testing.T) {
+	if err := RegisterModifier("TestFail", func(value string, args ModifierArgs) ([]string, error) {
+		return nil, errors.New("always fails")
+	}, ModifierSpec{}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := CompileMask("{sn#TestFail}!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := generatePWs(testEntry(), m, GenOptions{OnEmpty: ON_EMPTY_SKIP}); len(got) != 0 {
+		t.Errorf("generatePWs with a failing modifier = %q, want no candidates", got)
+	}
+}
+
+func TestCharsetCombinations(t *testing.T) {
+	tests := []struct {
+		mask string
+		want int
+	}{
+		{"Summer{YYYY}!", 1},
+		{"(Sommer|Winter){YYYY-1}", 1},
+		{"?d?d?s", 3300},
+		{"(A?d|B)?s", 363},
+	}
+	for _, tt := range tests {
+		m, err := CompileMask(tt.mask)
+		if err != nil {
+			t.Errorf("CompileMask(%q): %v", tt.mask, err)
+			continue
+		}
+		if got := m.CharsetCombinations(); got != tt.want {
+			t.Errorf("CharsetCombinations(%q) = %d, want %d", tt.mask, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMask(t *testing.T) {
+	tests := []struct {
+		text, literal, word string
+	}{
+		{"Foo(1)?", `Foo\(1\)\?`, `Foo\(1\)\?`},
+		{"{sn}|x", `\{sn\}\|x`, `{sn}\|x`},
+		{"{bad", `\{bad`, `\{bad`},
+		{`a\b`, `a\\b`, `a\\b`},
+	}
+	for _, tt := range tests {
+		if got := EscapeMaskLiteral(tt.text); got != tt.literal {
+			t.Errorf("EscapeMaskLiteral(%q) = %q, want %q", tt.text, got, tt.literal)
+		}
+		if got := EscapeMaskWord(tt.text); got != tt.word {
+			t.Errorf("EscapeMaskWord(%q) = %q, want %q", tt.text, got, tt.word)
+		}
+		// Escaped literals must parse back to the original text
+		m, err := ParseMask(EscapeMaskLiteral(tt.text))
+		if err != nil || len(m.Nodes) != 1 || m.Nodes[0].(*LiteralNode).Text != tt.text {
+			t.Errorf("ParseMask(EscapeMaskLiteral(%q)) did not return the text: %v", tt.text, err)
+		}
+	}
+}
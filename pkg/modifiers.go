@@ -0,0 +1,710 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// splitArgs splits a modifier argument list at unescaped commas and trims every argument.
+// A backslash escapes the next character, e.g. #PadRight(\,,8) pads with commas.
+func splitArgs(args string) []string {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range args {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	parts = append(parts, current.String())
+
+	for i := range parts {
+		// Keep a padding character like ' ' intact
+		if trimmed := strings.TrimSpace(parts[i]); trimmed != "" {
+			parts[i] = trimmed
+		}
+	}
+	return parts
+}
+
+// parseIntArgs parses a comma-separated list of exactly n integer modifier arguments
+func parseIntArgs(args string, n int) ([]int, error) {
+	parts := splitArgs(args)
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d integer argument(s), got %d", n, len(parts))
+	}
+
+	values := make([]int, n)
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer argument %q", strings.TrimSpace(part))
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// capitalize upper-cases the first rune and lower-cases the rest (e.g. "üLRICH" -> "Ülrich")
+func capitalize(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(runes[0])) + strings.ToLower(string(runes[1:]))
+}
+
+// firstRunes returns the first n runes of s
+func firstRunes(s string, n int) string {
+	return sliceRunes(s, 0, n)
+}
+
+// lastRunes returns the last n runes of s
+func lastRunes(s string, n int) string {
+	runes := []rune(s)
+	if n >= len(runes) {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// sliceRunes returns the runes from index start up to but excluding end. Negative indices
+// count from the end of the string, out of range indices are clamped.
+func sliceRunes(s string, start, end int) string {
+	runes := []rune(s)
+	clamp := func(i int) int {
+		if i < 0 {
+			i += len(runes)
+		}
+		return max(0, min(i, len(runes)))
+	}
+	start, end = clamp(start), clamp(end)
+	if start >= end {
+		return ""
+	}
+	return string(runes[start:end])
+}
+
+// splitWords splits a value like "Doe, John" or "IT Support" into words
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+}
+
+// nthWord returns the n-th word counted from 1, or from the end for negative n
+func nthWord(s string, n int) string {
+	words := splitWords(s)
+	if n < 0 {
+		n += len(words) + 1
+	}
+	if n < 1 || n > len(words) {
+		return ""
+	}
+	return words[n-1]
+}
+
+// initials returns the first rune of every word, hyphenated parts count as words (e.g. "Hans-Peter Mueller" -> "HPM")
+func initials(s string) string {
+	var result strings.Builder
+	for _, word := range splitWords(s) {
+		for _, part := range strings.Split(word, "-") {
+			for _, r := range part {
+				result.WriteRune(r)
+				break
+			}
+		}
+	}
+	return result.String()
+}
+
+// noParticle drops surname particles like von, van, der, de and la (e.g. "van der Berg" -> "Berg").
+// A name that only consists of particles is returned unchanged.
+func noParticle(s string) string {
+	particles := getNameParticles()
+	var kept []string
+	for _, word := range strings.Fields(s) {
+		if !particles[strings.ToLower(word)] {
+			kept = append(kept, word)
+		}
+	}
+	if len(kept) == 0 {
+		return s
+	}
+	return strings.Join(kept, " ")
+}
+
+// hyphenPart returns the n-th part of a hyphenated name, counted from 1 or from -1 for the last part
+// (e.g. "Müller-Lüdenscheidt", 2 -> "Lüdenscheidt")
+func hyphenPart(s string, n int) string {
+	parts := strings.Split(s, "-")
+	if n < 0 {
+		n += len(parts) + 1
+	}
+	if n < 1 || n > len(parts) {
+		return ""
+	}
+	return strings.TrimSpace(parts[n-1])
+}
+
+// surnameCore returns the main part of a surname: without particles, the first word and its
+// first hyphenated part (e.g. "de la Cruz" -> "Cruz", "Müller-Lüdenscheidt" -> "Müller")
+func surnameCore(s string) string {
+	words := strings.Fields(noParticle(s))
+	if len(words) == 0 {
+		return ""
+	}
+	return hyphenPart(words[0], 1)
+}
+
+// noSpaces removes all whitespace (e.g. "van der Berg" -> "vanderBerg")
+func noSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// swapCommaName turns "Last, First" into "First Last", values without a comma are unchanged
+func swapCommaName(s string) string {
+	last, first, ok := strings.Cut(s, ",")
+	if !ok || strings.TrimSpace(first) == "" {
+		return s
+	}
+	return strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+}
+
+// onlyDigits removes every rune that is not a digit (e.g. "+49 (30) 1234-56" -> "4930123456")
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// supportedTransliterations returns the sorted language codes usable with #Translit
+func supportedTransliterations() []string {
+	var languages []string
+	for lang := range getTransliterations() {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// transliterate replaces every rune found in the table. Replacements are fully upper-cased
+// inside upper-case words (e.g. "Über" -> "Ueber", "ÜBER" -> "UEBER", "WEIß" -> "WEISS").
+func transliterate(s string, table map[rune]string) string {
+	input := []rune(s)
+	var result strings.Builder
+	for i, r := range input {
+		replacement, found := table[r]
+		if !found {
+			result.WriteRune(r)
+			continue
+		}
+		nextUpper := i+1 < len(input) && unicode.IsUpper(input[i+1])
+		prevUpper := i > 0 && unicode.IsUpper(input[i-1])
+		// Letters like ß have no upper-case form and take the case of the previous letter
+		caseless := unicode.IsLower(r) && unicode.ToUpper(r) == r
+		if (unicode.IsUpper(r) && nextUpper) || (caseless && prevUpper && !(i+1 < len(input) && unicode.IsLower(input[i+1]))) {
+			replacement = strings.ToUpper(replacement)
+		}
+		result.WriteString(replacement)
+	}
+	return result.String()
+}
+
+// toASCII strips diacritics via Unicode decomposition (e.g. "Jürgen" -> "Jurgen"). Letters
+// that do not decompose, like ß or ø, are replaced with their usual ASCII spelling.
+func toASCII(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, s)
+	if err != nil {
+		result = s
+	}
+	return transliterate(result, getASCIIFallbacks())
+}
+
+// toggleCase switches a rune between upper and lower case
+func toggleCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
+
+// casedPositions returns the positions of all runes that have a different upper- or lower-case form
+func casedPositions(runes []rune) []int {
+	var positions []int
+	for i, r := range runes {
+		if toggleCase(r) != r {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// toggleFirst returns the value and the value with the case of the first letter toggled (e.g. "john" -> "john", "John")
+func toggleFirst(s string) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{s}
+	}
+	toggled := append([]rune{toggleCase(runes[0])}, runes[1:]...)
+	return uniqueStrings([]string{s, string(toggled)})
+}
+
+// toggleEach returns one variant per letter with the case of that letter toggled, like the hashcat rules T0, T1, ...
+func toggleEach(s string) []string {
+	runes := []rune(s)
+	positions := casedPositions(runes)
+	if len(positions) == 0 {
+		return []string{s}
+	}
+	variants := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		toggled := append([]rune{}, runes...)
+		toggled[pos] = toggleCase(toggled[pos])
+		variants = append(variants, string(toggled))
+	}
+	return variants
+}
+
+// casePerms returns up to limit case combinations of a value, starting with the value itself and
+// ordered by the number of toggled letters (e.g. "ab" -> "ab", "Ab", "aB", "AB")
+func casePerms(s string, limit int) []string {
+	runes := []rune(s)
+	positions := casedPositions(runes)
+	current := append([]rune{}, runes...)
+
+	var results []string
+	var toggle func(start, left int)
+	toggle = func(start, left int) {
+		if left == 0 {
+			results = append(results, string(current))
+			return
+		}
+		for p := start; p < len(positions) && len(results) < limit; p++ {
+			pos := positions[p]
+			current[pos] = toggleCase(runes[pos])
+			toggle(p+1, left-1)
+			current[pos] = runes[pos]
+		}
+	}
+	for count := 0; count <= len(positions) && len(results) < limit; count++ {
+		toggle(0, count)
+	}
+	return results
+}
+
+func init() {
+	registerBuiltinModifiers()
+}
+
+// simpleModifier wraps a transformation without arguments that returns a single value
+func simpleModifier(fn func(string) string) ModifierFunc {
+	return func(value string, _ ModifierArgs) ([]string, error) {
+		return []string{fn(value)}, nil
+	}
+}
+
+// validateInts returns a validator that parses n integer arguments and checks them
+func validateInts(n int, check func([]int) error) func(ModifierArgs) error {
+	return func(args ModifierArgs) error {
+		values, err := args.Ints(n)
+		if err != nil || check == nil {
+			return err
+		}
+		return check(values)
+	}
+}
+
+// EACH_MODIFIER marks placeholders that yield a candidate per value of a multi-valued attribute
+const EACH_MODIFIER = "Each"
+
+// registerBuiltinModifiers registers all built-in modifiers in the order they are listed by gen --help
+func registerBuiltinModifiers() {
+	// #Each is evaluated while resolving the placeholder, the modifier itself passes the value through
+	mustRegisterModifier(EACH_MODIFIER, func(value string, _ ModifierArgs) ([]string, error) {
+		return []string{value}, nil
+	}, ModifierSpec{
+		MaxArgs: 1,
+		Validate: validateInts(1, func(n []int) error {
+			if n[0] < 1 {
+				return fmt.Errorf("limit must be >= 1")
+			}
+			return nil
+		}),
+		Help: []ModifierHelp{
+			{"#Each", "One candidate per value of a multi-valued attribute, must be the first modifier e.g. {proxyAddresses#Each}"},
+			{"#Each(n)", "Only the first n values                e.g. {otherTelephone#Each(3)#Digits}"},
+		},
+	})
+	mustRegisterModifier("Upper", simpleModifier(strings.ToUpper), ModifierSpec{
+		Help: []ModifierHelp{{"#Upper", "Convert to uppercase                  e.g. {givenName#Upper} → JOHN"}},
+	})
+	mustRegisterModifier("Lower", simpleModifier(strings.ToLower), ModifierSpec{
+		Help: []ModifierHelp{{"#Lower", "Convert to lowercase                  e.g. {givenName#Lower} → john"}},
+	})
+	mustRegisterModifier("Title", simpleModifier(func(value string) string {
+		return cases.Title(language.English).String(strings.ToLower(value))
+	}), ModifierSpec{
+		Help: []ModifierHelp{{"#Title", "Capitalize first letter of each word  e.g. {givenName#Title} → John Smith"}},
+	})
+	mustRegisterModifier("Capitalize", simpleModifier(capitalize), ModifierSpec{
+		Help: []ModifierHelp{{"#Capitalize", "Capitalize first letter only          e.g. {givenName#Capitalize} → John"}},
+	})
+	mustRegisterModifier("AlternateLower", simpleModifier(func(value string) string {
+		// Convert to alternating case starting with lowercase (e.g., "Hello" -> "hElLo")
+		return alternateCase(value, false)
+	}), ModifierSpec{
+		Help: []ModifierHelp{{"#AlternateLower", "Alternating case, start lower         e.g. {givenName#AlternateLower} → jOhN"}},
+	})
+	mustRegisterModifier("AlternateUpper", simpleModifier(func(value string) string {
+		// Convert to alternating case starting with uppercase (e.g., "Hello" -> "HeLlO")
+		return alternateCase(value, true)
+	}), ModifierSpec{
+		Help: []ModifierHelp{{"#AlternateUpper", "Alternating case, start upper         e.g. {givenName#AlternateUpper} → JoHn"}},
+	})
+	mustRegisterModifier("Reverse", simpleModifier(Reverse), ModifierSpec{
+		Help: []ModifierHelp{{"#Reverse", "Reverse the string                    e.g. {givenName#Reverse} → nhoJ"}},
+	})
+	mustRegisterModifier("ToggleFirst", func(value string, _ ModifierArgs) ([]string, error) {
+		return toggleFirst(value), nil
+	}, ModifierSpec{
+		Help: []ModifierHelp{{"#ToggleFirst", "As is and with the first letter toggled e.g. {sAMAccountName#ToggleFirst} → jdoe, Jdoe"}},
+	})
+	mustRegisterModifier("ToggleEach", func(value string, _ ModifierArgs) ([]string, error) {
+		return toggleEach(value), nil
+	}, ModifierSpec{
+		Help: []ModifierHelp{{"#ToggleEach", "One letter toggled per candidate      e.g. {sn#ToggleEach} → doe, DOe, DoE"}},
+	})
+	mustRegisterModifier("CasePerms", func(value string, args ModifierArgs) ([]string, error) {
+		n, err := args.Ints(1)
+		if err != nil {
+			return nil, err
+		}
+		return casePerms(value, max(n[0], 1)), nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1,
+		Validate: validateInts(1, func(n []int) error {
+			if n[0] < 1 {
+				return fmt.Errorf("limit must be >= 1")
+			}
+			return nil
+		}),
+		Help: []ModifierHelp{{"#CasePerms(n)", "Up to n case combinations, fewest toggles first e.g. {sn#CasePerms(3)} → Doe, doe, DOe"}},
+	})
+	mustRegisterModifier("LeetBasic", simpleModifier(func(value string) string {
+		return leetSpeak(value, LEET_BASIC)
+	}), ModifierSpec{
+		Help: []ModifierHelp{{"#LeetBasic", "Substitute e→3, o→0, i→1, a→4"}},
+	})
+	mustRegisterModifier("LeetBasicPlus", simpleModifier(func(value string) string {
+		return leetSpeak(value, LEET_BASIC_PLUS)
+	}), ModifierSpec{
+		Help: []ModifierHelp{{"#LeetBasicPlus", "Substitute e→3, o→0, i→1, a→@, t→7"}},
+	})
+	mustRegisterModifier("Leet", func(value string, args ModifierArgs) ([]string, error) {
+		table, err := lookupLeetTable(args.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return leetFull(value, table), nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			_, err := lookupLeetTable(args.Raw)
+			return err
+		},
+		Help: []ModifierHelp{{"#Leet(table)", "Substitute with a leet table, one candidate per replacement e.g. {sn#Leet(a>4,@;e>3)} → D4v3, D@v3"}},
+	})
+	mustRegisterModifier("LeetPerm", func(value string, args ModifierArgs) ([]string, error) {
+		rules, maxSubs, err := parseLeetPermArgs(args.Raw)
+		if err != nil {
+			return nil, err
+		}
+		table, err := lookupLeetTable(rules)
+		if err != nil {
+			return nil, err
+		}
+		return leetVariants(value, table, maxSubs), nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			rules, _, err := parseLeetPermArgs(args.Raw)
+			if err == nil {
+				_, err = lookupLeetTable(rules)
+			}
+			return err
+		},
+		Help: []ModifierHelp{
+			{"#LeetPerm(table)", "Partial substitutions, fewest first   e.g. {sn#LeetPerm(a>4;o>0)} → P4ssword, Passw0rd, P4ssw0rd"},
+			{"#LeetPerm(n,table)", "At most n substitutions per candidate. Tables are inline, basic, basicplus or a --leet-file name"},
+		},
+	})
+	mustRegisterModifier("Pattern", func(value string, args ModifierArgs) ([]string, error) {
+		result, err := ApplyPattern(value, args.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return []string{result}, nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			if _, err := ParsePattern(args.Raw); err != nil {
+				return fmt.Errorf("invalid rule: %v", err)
+			}
+			return nil
+		},
+		Help: []ModifierHelp{{"#Pattern(x>y)", "Replace x with y, chain rules with ;  e.g. {sn#Pattern(e>3;a>4)} → l33tspeak"}},
+	})
+	mustRegisterModifier("Regex", func(value string, args ModifierArgs) ([]string, error) {
+		result, err := ApplyRegex(value, args.Raw)
+		if err != nil {
+			return nil, err
+		}
+		return []string{result}, nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			if _, err := ParseRegex(args.Raw); err != nil {
+				return fmt.Errorf("invalid rule: %v", err)
+			}
+			return nil
+		},
+		Help: []ModifierHelp{{"#Regex(re>repl)", "Regex replace, $1 for groups, chain with ; e.g. {description#Regex(^Joined (\\d+).*>$1)} → 2019"}},
+	})
+	mustRegisterModifier("Extract", func(value string, args ModifierArgs) ([]string, error) {
+		extracted, ok, err := ExtractRegex(value, args.Raw)
+		if err != nil || !ok {
+			// Without a match the value is unusable, like an empty attribute
+			return nil, err
+		}
+		return []string{extracted}, nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			if _, err := compileRegex(args.Raw); err != nil {
+				return fmt.Errorf("invalid expression: %v", err)
+			}
+			return nil
+		},
+		Help: []ModifierHelp{{"#Extract(re)", "First match or first capture group    e.g. {description#Extract(\\d{4})} → 2019, users without a match are skipped"}},
+	})
+
+	nonNegative := validateInts(1, func(n []int) error {
+		if n[0] < 0 {
+			return fmt.Errorf("length must be >= 0")
+		}
+		return nil
+	})
+	intModifier := func(fn func(string, int) string) ModifierFunc {
+		return func(value string, args ModifierArgs) ([]string, error) {
+			n, err := args.Ints(1)
+			if err != nil {
+				return nil, err
+			}
+			return []string{fn(value, n[0])}, nil
+		}
+	}
+	mustRegisterModifier("First", intModifier(firstRunes), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, Validate: nonNegative,
+		Help: []ModifierHelp{{"#First(n)", "First n characters                    e.g. {sn#First(3)} → Smi"}},
+	})
+	mustRegisterModifier("Last", intModifier(lastRunes), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, Validate: nonNegative,
+		Help: []ModifierHelp{{"#Last(n)", "Last n characters                     e.g. {sn#Last(2)} → th"}},
+	})
+	mustRegisterModifier("Slice", func(value string, args ModifierArgs) ([]string, error) {
+		n, err := args.Ints(2)
+		if err != nil {
+			return nil, err
+		}
+		return []string{sliceRunes(value, n[0], n[1])}, nil
+	}, ModifierSpec{
+		MinArgs: 2, MaxArgs: 2, Validate: validateInts(2, nil),
+		Help: []ModifierHelp{{"#Slice(a,b)", "Characters a to b-1, negative from end e.g. {sn#Slice(1,-1)} → mit"}},
+	})
+	mustRegisterModifier("Word", intModifier(nthWord), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1,
+		Validate: validateInts(1, func(n []int) error {
+			if n[0] == 0 {
+				return fmt.Errorf("words are counted from 1, or from -1 for the last word")
+			}
+			return nil
+		}),
+		Help: []ModifierHelp{{"#Word(n)", "n-th word, -1 for the last word       e.g. {cn#Word(2)} → Smith"}},
+	})
+	mustRegisterModifier("Initials", simpleModifier(initials), ModifierSpec{
+		Help: []ModifierHelp{{"#Initials", "First letter of every word            e.g. {cn#Initials} → JS"}},
+	})
+	mustRegisterModifier("Digits", simpleModifier(onlyDigits), ModifierSpec{
+		Help: []ModifierHelp{{"#Digits", "Keep only digits                      e.g. {telephoneNumber#Digits} → 4930123"}},
+	})
+	mustRegisterModifier("NoParticle", simpleModifier(noParticle), ModifierSpec{
+		Help: []ModifierHelp{{"#NoParticle", "Drop particles like von, van, der, de, la e.g. {sn#NoParticle} → van der Berg → Berg"}},
+	})
+	mustRegisterModifier("HyphenPart", intModifier(hyphenPart), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1,
+		Validate: validateInts(1, func(n []int) error {
+			if n[0] == 0 {
+				return fmt.Errorf("parts are counted from 1, or from -1 for the last part")
+			}
+			return nil
+		}),
+		Help: []ModifierHelp{{"#HyphenPart(n)", "n-th part of a hyphenated name, -1 for the last e.g. {sn#HyphenPart(2)} → Lüdenscheidt"}},
+	})
+	mustRegisterModifier("SurnameCore", simpleModifier(surnameCore), ModifierSpec{
+		Help: []ModifierHelp{{"#SurnameCore", "Main part of a surname                e.g. {sn#SurnameCore} → de la Cruz → Cruz, Müller-Lüdenscheidt → Müller"}},
+	})
+	mustRegisterModifier("NoSpaces", simpleModifier(noSpaces), ModifierSpec{
+		Help: []ModifierHelp{{"#NoSpaces", "Remove all spaces                     e.g. {sn#NoSpaces} → vanderBerg"}},
+	})
+	mustRegisterModifier("SwapCommaName", simpleModifier(swapCommaName), ModifierSpec{
+		Help: []ModifierHelp{{"#SwapCommaName", "\"Last, First\" to \"First Last\"        e.g. {cn#SwapCommaName} → John Doe"}},
+	})
+	mustRegisterModifier("Nick", func(value string, args ModifierArgs) ([]string, error) {
+		return nicknames(value, args.List()), nil
+	}, ModifierSpec{
+		// Any number of dictionaries, checked by Validate
+		MaxArgs: 1, RawArgs: true,
+		Validate: func(args ModifierArgs) error {
+			return checkNickDictionaries(args.List())
+		},
+		Help: []ModifierHelp{
+			{"#Nick", "Name and its nicknames                e.g. {givenName#Nick} → Robert, Bob, Rob, Bobby, Robbie"},
+			{"#Nick(de,en)", "Only the given dictionaries, built-in de and en or a --nick-file name"},
+		},
+	})
+	mustRegisterModifier("Translit", func(value string, args ModifierArgs) ([]string, error) {
+		list := args.List()
+		table, ok := getTransliterations()[strings.ToLower(list[0])]
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q", list[0])
+		}
+		translit := transliterate(value, table)
+		if len(list) == 2 && list[1] == "both" {
+			return uniqueStrings([]string{translit, value}), nil
+		}
+		return []string{translit}, nil
+	}, ModifierSpec{
+		MinArgs: 1, MaxArgs: 2,
+		Validate: func(args ModifierArgs) error {
+			list := args.List()
+			if _, ok := getTransliterations()[strings.ToLower(list[0])]; !ok {
+				return fmt.Errorf("unknown language %q, supported: %s", list[0], strings.Join(supportedTransliterations(), ", "))
+			}
+			if len(list) == 2 && list[1] != "both" {
+				return fmt.Errorf("second argument must be 'both'")
+			}
+			return nil
+		},
+		Help: []ModifierHelp{
+			{"#Translit(de)", "Transliterate (de, da, no)            e.g. {givenName#Translit(de)} → Juergen"},
+			{"#Translit(de,both)", "Transliterated and original form      e.g. {givenName#Translit(de,both)} → Juergen, Jürgen"},
+		},
+	})
+	mustRegisterModifier("Ascii", func(value string, args ModifierArgs) ([]string, error) {
+		ascii := toASCII(value)
+		if args.Present && strings.TrimSpace(args.Raw) == "both" {
+			return uniqueStrings([]string{ascii, value}), nil
+		}
+		return []string{ascii}, nil
+	}, ModifierSpec{
+		MaxArgs: 1,
+		Validate: func(args ModifierArgs) error {
+			if args.List()[0] != "both" {
+				return fmt.Errorf("argument must be 'both'")
+			}
+			return nil
+		},
+		Help: []ModifierHelp{
+			{"#Ascii", "Strip diacritics                      e.g. {givenName#Ascii} → Jurgen"},
+			{"#Ascii(both)", "ASCII and original form               e.g. {givenName#Ascii(both)} → Jurgen, Jürgen"},
+		},
+	})
+	mustRegisterModifier("NFC", simpleModifier(norm.NFC.String), ModifierSpec{
+		Help: []ModifierHelp{{"#NFC / #NFD", "Unicode normalization (composed / decomposed)"}},
+	})
+	mustRegisterModifier("NFD", simpleModifier(norm.NFD.String), ModifierSpec{})
+
+	pad := func(left bool) ModifierFunc {
+		return func(value string, args ModifierArgs) ([]string, error) {
+			list := args.List()
+			n, err := parseIntArgs(list[1], 1)
+			if err != nil {
+				return nil, err
+			}
+			padding := strings.Repeat(list[0], max(n[0]-utf8.RuneCountInString(value), 0))
+			if left {
+				return []string{padding + value}, nil
+			}
+			return []string{value + padding}, nil
+		}
+	}
+	validatePad := func(args ModifierArgs) error {
+		list := args.List()
+		if utf8.RuneCountInString(list[0]) != 1 {
+			return fmt.Errorf("the padding must be a single character, escape ',' as \\,")
+		}
+		_, err := parseIntArgs(list[1], 1)
+		return err
+	}
+	mustRegisterModifier("PadLeft", pad(true), ModifierSpec{
+		MinArgs: 2, MaxArgs: 2, Validate: validatePad,
+		Help: []ModifierHelp{{"#PadLeft(c,n)", "Pad on the left with c to n characters e.g. {postalCode#PadLeft(0,6)} → 012345"}},
+	})
+	mustRegisterModifier("PadRight", pad(false), ModifierSpec{
+		MinArgs: 2, MaxArgs: 2, Validate: validatePad,
+		Help: []ModifierHelp{{"#PadRight(c,n)", "Pad on the right with c to n characters e.g. {sn#PadRight(!,6)} → Doe!!!"}},
+	})
+	mustRegisterModifier("Truncate", intModifier(firstRunes), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1, Validate: nonNegative,
+		Help: []ModifierHelp{{"#Truncate(n)", "Cut to at most n characters           e.g. {sn#Truncate(3)} → Smi"}},
+	})
+	mustRegisterModifier("Repeat", intModifier(func(value string, n int) string {
+		return strings.Repeat(value, max(n, 1))
+	}), ModifierSpec{
+		MinArgs: 1, MaxArgs: 1,
+		Validate: validateInts(1, func(n []int) error {
+			if n[0] < 1 {
+				return fmt.Errorf("count must be >= 1")
+			}
+			return nil
+		}),
+		Help: []ModifierHelp{{"#Repeat(n)", "Repeat the value n times              e.g. {sn#Repeat(2)} → DoeDoe"}},
+	})
+}
+
+// alternateCase alternates upper and lower case, starting with upper if upperFirst is set
+func alternateCase(s string, upperFirst bool) string {
+	runes := []rune(s)
+	for i := range runes {
+		if (i%2 == 0) == upperFirst {
+			runes[i] = unicode.ToUpper(runes[i])
+		} else {
+			runes[i] = unicode.ToLower(runes[i])
+		}
+	}
+	return string(runes)
+}
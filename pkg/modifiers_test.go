@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+// compileModifiers returns the modifier chain of a {sn...} mask
+func compileModifiers(t *testing.T, chain string) []ModifierCall {
+	t.Helper()
+	m, err := CompileMask("{sn" + chain + "}")
+	if err != nil {
+		t.Fatalf("CompileMask({sn%s}): %v", chain, err)
+	}
+	return m.Nodes[0].(*PlaceholderNode).Modifiers
+}
+
+func TestModifiers(t *testing.T) {
+	tests := []struct {
+		chain string
+		value string
+		want  []string
+	}{
+		{"#Upper", "john", []string{"JOHN"}},
+		{"#Lower", "JOHN", []string{"john"}},
+		{"#Capitalize", "john", []string{"John"}},
+		{"#Reverse", "John", []string{"nhoJ"}},
+		{"#Upper#Reverse", "john", []string{"NHOJ"}},
+		{"#First(3)", "Smith", []string{"Smi"}},
+		{"#Last(2)", "Smith", []string{"th"}},
+		{"#Slice(1,-1)", "Smith", []string{"mit"}},
+		{"#Word(2)", "John Doe", []string{"Doe"}},
+		{"#Initials", "John Doe", []string{"JD"}},
+		{"#Digits", "+49 (30) 1234-56", []string{"4930123456"}},
+		{"#Ascii", "Jürgen", []string{"Jurgen"}},
+		{"#PadLeft(0,6)", "12345", []string{"012345"}},
+		{"#PadRight(!,6)", "Doe", []string{"Doe!!!"}},
+		{"#Truncate(3)", "Smith", []string{"Smi"}},
+		{"#Repeat(2)", "ab", []string{"abab"}},
+		{"#Pattern(a>4;e>3)", "Peter", []string{"P3t3r"}},
+		{"#Extract(\\d+)", "Room 412", []string{"412"}},
+		{"#Extract(\\d+)", "no digits", []string{}},
+		{"#Regex(^(\\w+) (\\w+)>$2$1)", "John Doe", []string{"DoeJohn"}},
+		{"#ToggleFirst", "Doe", []string{"Doe", "doe"}},
+		{"#CasePerms(3)", "Doe", []string{"Doe", "doe", "DOe"}},
+		{"#NoParticle", "van der Berg", []string{"Berg"}},
+		{"#SwapCommaName", "Doe, John", []string{"John Doe"}},
+		{"#NoSpaces", "van der Berg", []string{"vanderBerg"}},
+	}
+	for _, tt := range tests {
+		got, err := applyModifiers(tt.value, compileModifiers(t, tt.chain))
+		if err != nil {
+			t.Errorf("%s on %q: %v", tt.chain, tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("%s on %q = %q, want %q", tt.chain, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNickModifier(t *testing.T) {
+	got, err := applyModifiers("Robert", compileModifiers(t, "#Nick(en)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) < 2 || got[0] != "Robert" || !containsFold(got, "Bob") {
+		t.Errorf("#Nick(en) on Robert = %q, want Robert followed by nicknames like Bob", got)
+	}
+	for _, nickname := range got {
+		if nickname != capitalize(nickname) {
+			t.Errorf("#Nick(en) on Robert returned %q, want the case of the value", nickname)
+		}
+	}
+}
+
+func TestModifierValidation(t *testing.T) {
+	tests := []string{
+		"#Nope",
+		"#Upper(1)",
+		"#First",
+		"#First(x)",
+		"#Slice(1)",
+		"#PadLeft(0)",
+		"#Regex(([a-z>x)",
+		"#Extract(\\d+",
+		"#Nick(xx)",
+		"#Translit(xx)",
+		"#Upper#Each",
+	}
+	for _, chain := range tests {
+		if _, err := CompileMask("{sn" + chain + "}"); err == nil {
+			t.Errorf("CompileMask({sn%s}) succeeded, want an error", chain)
+		}
+	}
+}
+
+func TestRegisterModifier(t *testing.T) {
+	noop := func(value string, args ModifierArgs) ([]string, error) { return []string{value}, nil }
+	if err := RegisterModifier("Upper", noop, ModifierSpec{}); err == nil {
+		t.Error("RegisterModifier(Upper) succeeded, want an error for a built-in name")
+	}
+	if err := RegisterModifier("Bad Name", noop, ModifierSpec{}); err == nil {
+		t.Error("RegisterModifier(Bad Name) succeeded, want an error for an invalid name")
+	}
+
+	if err := RegisterModifier("TestSurround", func(value string, args ModifierArgs) ([]string, error) {
+		return []string{args.List()[0] + value + args.List()[0]}, nil
+	}, ModifierSpec{MinArgs: 1, MaxArgs: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompileMask("{sn#TestSurround}"); err == nil {
+		t.Error("TestSurround without its argument compiled, want an error")
+	}
+	got, err := applyModifiers("Doe", compileModifiers(t, "#TestSurround(*)"))
+	if err != nil || !reflect.DeepEqual(got, []string{"*Doe*"}) {
+		t.Errorf("#TestSurround(*) on Doe = %q, %v, want *Doe*", got, err)
+	}
+}
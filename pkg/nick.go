@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// customNicknames holds the dictionaries loaded with LoadNickFile by lower-case name
+var customNicknames = map[string]map[string][]string{}
+
+// LoadNickFile loads a nickname dictionary from a file with one name per line like
+// "Robert: Bob, Rob, Bobby" and registers it under the file name without extension.
+// A file named like a built-in language (en.txt, de.txt) extends that dictionary.
+// Empty lines and lines starting with # are ignored. It returns the name and the number of names.
+func LoadNickFile(path string) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading nickname file: %v", err)
+	}
+	defer f.Close()
+
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if name == "" || strings.ContainsAny(name, ",()") {
+		return "", 0, fmt.Errorf("nickname file %s: invalid dictionary name %q", path, name)
+	}
+	dictionary := customNicknames[name]
+	if dictionary == nil {
+		dictionary = map[string][]string{}
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		givenName, nicknames, ok := strings.Cut(line, ":")
+		givenName = strings.ToLower(strings.TrimSpace(givenName))
+		if !ok || givenName == "" {
+			return "", 0, fmt.Errorf("%s:%d: expected name: nickname, nickname", path, lineNumber)
+		}
+		for _, nickname := range strings.Split(nicknames, ",") {
+			if nickname = strings.ToLower(strings.TrimSpace(nickname)); nickname != "" {
+				dictionary[givenName] = appendUnique(dictionary[givenName], nickname)
+			}
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, fmt.Errorf("error reading nickname file: %v", err)
+	}
+	customNicknames[name] = dictionary
+	return name, count, nil
+}
+
+// supportedNickDictionaries returns the built-in languages followed by the loaded dictionaries
+func supportedNickDictionaries() []string {
+	builtin := getNicknames()
+	names := []string{"de", "en"}
+	var custom []string
+	for name := range customNicknames {
+		if _, ok := builtin[name]; !ok {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// checkNickDictionaries returns an error for the first unknown dictionary name
+func checkNickDictionaries(names []string) error {
+	builtin := getNicknames()
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if _, ok := builtin[name]; ok {
+			continue
+		}
+		if _, ok := customNicknames[name]; !ok {
+			return fmt.Errorf("unknown nickname dictionary %q, supported: %s", name, strings.Join(supportedNickDictionaries(), ", "))
+		}
+	}
+	return nil
+}
+
+// nicknames returns the value followed by its nicknames from the given dictionaries, all of them
+// if none are given. Names with several words are looked up as a whole, then by their first word.
+// Nicknames take the case of the value: John → Johnny, JOHN → JOHNNY, john → johnny.
+func nicknames(value string, dictionaries []string) []string {
+	if len(dictionaries) == 0 {
+		dictionaries = supportedNickDictionaries()
+	}
+	builtin := getNicknames()
+	lookup := func(name string) []string {
+		var found []string
+		for _, dictionary := range dictionaries {
+			dictionary = strings.ToLower(dictionary)
+			for _, nickname := range builtin[dictionary][name] {
+				found = appendUnique(found, nickname)
+			}
+			for _, nickname := range customNicknames[dictionary][name] {
+				found = appendUnique(found, nickname)
+			}
+		}
+		return found
+	}
+
+	found := lookup(strings.ToLower(value))
+	if len(found) == 0 {
+		if words := splitWords(value); len(words) > 1 {
+			found = lookup(strings.ToLower(words[0]))
+		}
+	}
+
+	results := []string{value}
+	for _, nickname := range found {
+		results = append(results, matchCase(nickname, value))
+	}
+	return uniqueStrings(results)
+}
+
+// matchCase converts a lower-case word to the case style of model: upper, lower or capitalized
+func matchCase(word, model string) string {
+	first, _ := utf8.DecodeRuneInString(model)
+	switch {
+	case model == strings.ToUpper(model) && utf8.RuneCountInString(model) > 1:
+		return strings.ToUpper(word)
+	case unicode.IsUpper(first):
+		return capitalize(word)
+	}
+	return word
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -107,3 +108,99 @@ func ApplyPattern(input string, pattern string) (string, error) {
 
 	return result, nil
 }
+
+// RegexReplacement is a single compiled #Regex(expr>repl) rule
+type RegexReplacement struct {
+	Expr *regexp.Regexp
+	Repl string
+}
+
+// unescapeRegex only removes the escapes of the rule separators \> and \;. All other
+// escapes like \d or \( are passed on to the regular expression.
+func unescapeRegex(s string) string {
+	return strings.NewReplacer(`\>`, ">", `\;`, ";").Replace(s)
+}
+
+// splitRegexRules splits a rule list at unescaped separators, keeping all escapes
+func splitRegexRules(rules string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(rules); i++ {
+		switch rules[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, rules[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, rules[start:])
+}
+
+// ParseRegex parses and compiles rules in the format "expr>repl;expr2>repl2". The replacement
+// may reference capture groups as $1 or ${name}.
+func ParseRegex(rules string) ([]RegexReplacement, error) {
+	var replacements []RegexReplacement
+	for _, rule := range splitRegexRules(rules, ';') {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		exprRepl := splitRegexRules(rule, '>')
+		if len(exprRepl) != 2 {
+			return nil, fmt.Errorf("invalid regex rule '%s', expected 'expr>repl' (escape '>' as \\>)", rule)
+		}
+		expr, err := compileRegex(exprRepl[0])
+		if err != nil {
+			return nil, err
+		}
+		replacements = append(replacements, RegexReplacement{Expr: expr, Repl: unescapeRegex(exprRepl[1])})
+	}
+	if len(replacements) == 0 {
+		return nil, fmt.Errorf("empty regex rule")
+	}
+	return replacements, nil
+}
+
+// compileRegex compiles a regular expression with the escaping rules of ParseRegex
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	expr = unescapeRegex(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("regular expression cannot be empty")
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %v", expr, err)
+	}
+	return compiled, nil
+}
+
+// ApplyRegex applies the regex replacements to the input string in order
+func ApplyRegex(input string, rules string) (string, error) {
+	replacements, err := ParseRegex(rules)
+	if err != nil {
+		return "", err
+	}
+
+	result := input
+	for _, replacement := range replacements {
+		result = replacement.Expr.ReplaceAllString(result, replacement.Repl)
+	}
+	return result, nil
+}
+
+// ExtractRegex returns the first match of expr in the input, or its first capture group if
+// the expression has one. ok is false if the expression does not match.
+func ExtractRegex(input string, expr string) (string, bool, error) {
+	compiled, err := compileRegex(expr)
+	if err != nil {
+		return "", false, err
+	}
+	match := compiled.FindStringSubmatch(input)
+	if match == nil {
+		return "", false, nil
+	}
+	if len(match) > 1 {
+		return match[1], true, nil
+	}
+	return match[0], true, nil
+}
@@ -0,0 +1,26 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// PolicySet holds the default domain password policy and the readable fine-grained
+// password policies (PSOs) keyed by lower-case DN
+type PolicySet struct {
+	Domain *PasswordPolicy
+	PSOs   map[string]*PasswordPolicy
+}
+
+// PolicyFor returns the policy that applies to an entry: the PSO referenced by
+// msDS-ResultantPSO if it could be read, otherwise the domain policy. It returns
+// nil if no policy is known.
+func (ps PolicySet) PolicyFor(entry *ldap.Entry) *PasswordPolicy {
+	if dn := strings.TrimSpace(entry.GetAttributeValue("msDS-ResultantPSO")); dn != "" {
+		if pso, ok := ps.PSOs[strings.ToLower(dn)]; ok {
+			return pso
+		}
+	}
+	return ps.Domain
+}
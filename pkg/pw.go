@@ -1,15 +1,14 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-ldap/ldap/v3"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
 const (
@@ -24,44 +23,269 @@ const (
 	LEET_BASIC_PLUS = 1
 )
 
-func generatePW(entry *ldap.Entry, mask string) string {
-	// Regular expression pattern to match placeholders with optional modifiers
-	pattern := `\{([^{}]+?)(?:#([^{}]+))?\}`
+const (
+	ON_EMPTY_SKIP     = "skip"
+	ON_EMPTY_KEEP     = "keep"
+	ON_EMPTY_FALLBACK = "fallback"
+)
+
+// GenOptions controls how passwords are generated from masks
+type GenOptions struct {
+	// OnEmpty decides what happens to placeholders that resolve to an empty value:
+	// skip drops the candidate, keep inserts an empty string and fallback uses the sAMAccountName
+	OnEmpty string
+	// Policies are used to look up the password policy of every user
+	Policies PolicySet
+	// Location is the time zone date placeholders are computed in, the local time zone if nil
+	Location *time.Location
+	// Locale is used by {Month} and {Season} placeholders without an explicit locale
+	Locale string
+	// Rules are hashcat rules applied to every candidate of a mask
+	Rules []Rule
+	// MaxCandidates caps the candidates per user and mask, 0 for no limit
+	MaxCandidates int
+	// MaxPerUser caps the candidates per user after the masks are merged, 0 for no limit
+	MaxPerUser int
+	// MaxRounds aborts before any output is written if there are more spray rounds, 0 for no limit
+	MaxRounds int
+	// Domain holds the domain names of the built-in variables like {$netbios}
+	Domain DomainInfo
+	// PolicyFilter drops candidates the password policy of the user would reject
+	PolicyFilter bool
+	// PolicyReport is the file the dropped candidates are written to, no report if empty
+	PolicyReport string
+}
+
+const (
+	FIT_DIGITS = "digits"
+	FIT_REPEAT = "repeat"
+)
 
-	// Compile the regular expression
-	re := regexp.MustCompile(pattern)
+// fitMarker is inserted in place of a {!fit} directive until the length of the complete candidate is known
+const fitMarker = "\uE000"
 
-	// Replace placeholders with function calls
-	replacedMask := re.ReplaceAllStringFunc(mask, func(match string) string {
-		// Extract the placeholder and modifier (if any) without curly braces
-		matches := re.FindStringSubmatch(match)
-		placeholder := matches[1]
-		modifier := ""
-		if len(matches) > 2 {
-			modifier = matches[2]
+// maskContext holds everything needed to evaluate a mask for a single entry
+type maskContext struct {
+	entry  *ldap.Entry
+	mask   *Mask
+	opts   GenOptions
+	policy *PasswordPolicy
+	fit    *DirectiveNode
+}
+
+// generatePWs returns all password candidates of a mask for an entry. Placeholder and group
+// alternatives expand to the cartesian product, duplicates are removed. Depending on the
+// OnEmpty option no candidates are returned if a placeholder cannot be resolved.
+// Rules are applied last.
+func generatePWs(entry *ldap.Entry, mask *Mask, opts GenOptions) []string {
+	ctx := &maskContext{entry: entry, mask: mask, opts: opts, policy: opts.Policies.PolicyFor(entry)}
+	if mask.Template != nil {
+		candidates, err := ctx.executeTemplate(mask)
+		if errors.Is(err, errEmptyPlaceholder) {
+			return nil
+		}
+		if err != nil {
+			if !mask.templateFailed {
+				mask.templateFailed = true
+				PrintWarning(fmt.Sprintf("Template %s failed for %s, users it fails for are skipped: %v", mask.Raw, entry.GetAttributeValue("sAMAccountName"), err))
+			}
+			return nil
 		}
-		placeholderLowercase := strings.ToLower(placeholder)
+		return applyRules(uniqueStrings(candidates), opts.Rules)
+	}
+	candidates := ctx.expandNodes(mask.Nodes)
+	if ctx.fit != nil {
+		for i, candidate := range candidates {
+			candidates[i] = ctx.applyFit(candidate)
+		}
+	}
+	return applyRules(uniqueStrings(candidates), opts.Rules)
+}
+
+// applyFit replaces the fit marker with the shortest fill that brings the candidate to the
+// minimum password length of the user's policy
+func (ctx *maskContext) applyFit(candidate string) string {
+	if !strings.Contains(candidate, fitMarker) {
+		return candidate
+	}
+	missing := 0
+	if ctx.policy != nil {
+		missing = ctx.policy.MinPwdLength - utf8.RuneCountInString(strings.ReplaceAll(candidate, fitMarker, ""))
+	}
 
-		// Call the appropriate function based on the placeholder and modifier
-		switch placeholderLowercase {
-		case "yy", "yyyy", "m", "mm", "monthgerman", "monthenglish", "seasongerman", "seasonamerican", "seasonbritish":
-			year, _ := convertDate(convertTime(entry.GetAttributeValue("pwdLastSet")), placeholder)
-			return year
+	var fill string
+	if missing > 0 {
+		switch ctx.fit.Mode {
+		case FIT_REPEAT:
+			// Repeat the whole unit, so "12" is never cut to "121"
+			unit := utf8.RuneCountInString(ctx.fit.Arg)
+			fill = strings.Repeat(ctx.fit.Arg, (missing+unit-1)/unit)
 		default:
-			// If the placeholder is not recognized, try to get it from the ldap entry
-			value := entry.GetAttributeValue(placeholder)
-			if modifier != "" {
-				result, err := applyModifier(value, modifier)
-				if err != nil {
-					return value
+			fill = firstRunes(strings.Repeat("1234567890", missing/10+1), missing)
+		}
+	}
+	return strings.ReplaceAll(candidate, fitMarker, fill)
+}
+
+// expandNodes returns every combination of the values of a node sequence
+func (ctx *maskContext) expandNodes(nodes []MaskNode) []string {
+	results := []string{""}
+	for _, node := range nodes {
+		var values []string
+		switch n := node.(type) {
+		case *LiteralNode:
+			values = []string{n.Text}
+		case *PlaceholderNode:
+			values = ctx.resolvePlaceholder(n)
+		case *GroupNode:
+			for _, alternative := range n.Alternatives {
+				values = append(values, ctx.expandNodes(alternative)...)
+			}
+		case *DirectiveNode:
+			ctx.fit = n
+			values = []string{fitMarker}
+		case *CharsetNode:
+			chars, _ := charsetChars(n.Charset)
+			for _, char := range chars {
+				values = append(values, string(char))
+			}
+		}
+
+		combined := make([]string, 0, len(results)*len(values))
+		for _, prefix := range results {
+			for _, value := range values {
+				combined = append(combined, prefix+value)
+			}
+		}
+		results = combined
+	}
+	return results
+}
+
+// resolvePlaceholder returns the value of every resolvable placeholder alternative with all modifiers applied.
+// With a leading #Each every value of a multi-valued attribute is used instead of only the first one.
+func (ctx *maskContext) resolvePlaceholder(placeholder *PlaceholderNode) []string {
+	modifiers := placeholder.Modifiers
+	limit := 1
+	if len(modifiers) > 0 && modifiers[0].Name == EACH_MODIFIER {
+		limit = 0
+		if modifiers[0].HasArgs {
+			limit, _ = ModifierArgs{Raw: modifiers[0].Args, Present: true}.Int(0)
+		}
+		modifiers = modifiers[1:]
+	}
+
+	var values []string
+	for _, alternative := range placeholder.Alternatives {
+		resolved, ok := ctx.resolveAlternative(alternative, limit)
+		if !ok {
+			continue
+		}
+		for _, value := range resolved {
+			results, err := applyModifiers(value, modifiers)
+			if err != nil {
+				// The unmodified value is never sprayed in place of the modified one
+				if !ctx.mask.modifierFailed {
+					ctx.mask.modifierFailed = true
+					PrintWarning(fmt.Sprintf("Modifier failed in mask %s for %s, values it fails for are dropped: %v", ctx.mask.Raw, ctx.entry.GetAttributeValue("sAMAccountName"), err))
 				}
-				return result
+				continue
+			}
+			values = append(values, results...)
+		}
+	}
+	return uniqueStrings(values)
+}
+
+// resolveAlternative walks the fallback chain of an alternative and returns up to limit values
+// (0 for all) of the first non-empty reference. ok is false if the alternative is empty and the
+// OnEmpty option drops such candidates.
+func (ctx *maskContext) resolveAlternative(alternative PlaceholderAlt, limit int) (values []string, ok bool) {
+	onlyDates := true
+	for _, ref := range alternative.Chain {
+		if values = ctx.resolveRefValues(ref.Name); len(values) > 0 {
+			// Only attribute values are limited, generators always yield all of their values
+			if limit > 0 && len(values) > limit && isAttribute(ref.Name) {
+				values = values[:limit]
+			}
+			return values, true
+		}
+		onlyDates = onlyDates && isDatePlaceholder(ref.Name)
+	}
+	if alternative.HasDefault {
+		return []string{alternative.Default}, true
+	}
+
+	if value, ok := ctx.emptyValue(onlyDates); ok {
+		return []string{value}, true
+	}
+	return nil, false
+}
+
+// emptyValue returns the replacement for an empty placeholder according to the OnEmpty option,
+// ok is false if the candidate is dropped
+func (ctx *maskContext) emptyValue(isDate bool) (string, bool) {
+	switch ctx.opts.OnEmpty {
+	case ON_EMPTY_KEEP:
+		return "", true
+	case ON_EMPTY_FALLBACK:
+		// A logon name is no meaningful replacement for a date
+		if !isDate {
+			if value := ctx.entry.GetAttributeValue("sAMAccountName"); value != "" {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolveRefValues returns all non-empty values of an attribute, every keyboard walk of a
+// {keywalk} placeholder or the value of any other placeholder
+func (ctx *maskContext) resolveRefValues(name string) []string {
+	if isKeywalkPlaceholder(name) {
+		spec, _ := keywalkPlaceholderSpec(name)
+		return KeyWalks(spec)
+	}
+	if isAttribute(name) && !isDatePlaceholder(name) {
+		var values []string
+		for _, value := range ctx.entry.GetEqualFoldAttributeValues(name) {
+			if value != "" {
+				values = append(values, value)
 			}
-			return value
 		}
-	})
+		return values
+	}
+	if value := ctx.resolveRef(name); value != "" {
+		return []string{value}
+	}
+	return nil
+}
+
+// resolveRef returns the raw value of a single attribute, date, variable or registered placeholder
+func (ctx *maskContext) resolveRef(name string) string {
+	if placeholder, ok := lookupPlaceholder(name); ok {
+		return placeholder.resolver(PlaceholderContext{Entry: ctx.entry, Options: ctx.opts, Policy: ctx.policy})
+	}
+	if isVariablePlaceholder(name) {
+		return ctx.resolveVariable(name)
+	}
+	if spec, ok := parseDatePlaceholder(name); ok {
+		return ctx.resolveDate(spec)
+	}
+	return ctx.entry.GetEqualFoldAttributeValue(name)
+}
 
-	return replacedMask
+// uniqueStrings removes duplicates while keeping the order of first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
 }
 
 func leetSpeak(input string, technique int) string {
@@ -94,67 +318,6 @@ func leetSpeak(input string, technique int) string {
 	return result
 }
 
-// convertDate converts a date string into the desired format
-func convertDate(dateString string, format string) (string, error) {
-	t, err := time.Parse("2006-01-02", dateString)
-	if err != nil {
-		return "", err
-	}
-
-	switch strings.ToLower(format) {
-	case "yyyy":
-		return fmt.Sprintf("%d", t.Year()), nil
-	case "yy":
-		// Get last two numbers of the year
-		year := t.Year() % 100
-		return fmt.Sprintf("%02d", year), nil
-	case "mm":
-		return fmt.Sprintf("%02d", t.Month()), nil
-	case "m":
-		return fmt.Sprintf("%d", t.Month()), nil
-	case "monthgerman":
-		return getGermanMonths()[t.Month()-1], nil
-	case "monthenglish":
-		return getEnglishMonths()[t.Month()-1], nil
-	case "seasongerman":
-		switch t.Month() {
-		case time.December, time.January, time.February:
-			return getGermanSeasons()[WINTER], nil
-		case time.March, time.April, time.May:
-			return getGermanSeasons()[SPRING], nil
-		case time.June, time.July, time.August:
-			return getGermanSeasons()[SUMMER], nil
-		case time.September, time.October, time.November:
-			return getGermanSeasons()[AUTUMN], nil
-		}
-	case "seasonamerican":
-		switch t.Month() {
-		case time.December, time.January, time.February:
-			return getAmericanSeasons()[WINTER], nil
-		case time.March, time.April, time.May:
-			return getAmericanSeasons()[SPRING], nil
-		case time.June, time.July, time.August:
-			return getAmericanSeasons()[SUMMER], nil
-		case time.September, time.October, time.November:
-			return getAmericanSeasons()[AUTUMN], nil
-		}
-	case "seasonbritish":
-		switch t.Month() {
-		case time.December, time.January, time.February:
-			return getBritishSeasons()[WINTER], nil
-		case time.March, time.April, time.May:
-			return getBritishSeasons()[SPRING], nil
-		case time.June, time.July, time.August:
-			return getBritishSeasons()[SUMMER], nil
-		case time.September, time.October, time.November:
-			return getBritishSeasons()[AUTUMN], nil
-		}
-	default:
-		return "", fmt.Errorf("unsupported format")
-	}
-	return "", nil
-}
-
 // Reverse returns the reversed string
 func Reverse(s string) string {
 	runes := []rune(s)
@@ -163,121 +326,3 @@ func Reverse(s string) string {
 	}
 	return string(runes)
 }
-
-// splitModifiers splits a modifier string into individual modifiers
-func splitModifiers(modifierString string) []string {
-	var modifiers []string
-	var currentModifier strings.Builder
-	parenCount := 0
-	escaped := false
-
-	for i := 0; i < len(modifierString); i++ {
-		char := modifierString[i]
-
-		// Handle escape character
-		if char == '\\' && !escaped {
-			escaped = true
-			continue
-		}
-
-		// Handle parentheses counting
-		if !escaped {
-			if char == '(' {
-				parenCount++
-			} else if char == ')' {
-				parenCount--
-			}
-		}
-
-		// Handle modifier separator
-		if char == '#' && !escaped && parenCount == 0 {
-			// If we have collected a modifier, add it to the list
-			if currentModifier.Len() > 0 {
-				modifiers = append(modifiers, currentModifier.String())
-				currentModifier.Reset()
-			}
-			continue
-		}
-
-		// Add the character to the current modifier
-		if escaped && char == '#' {
-			// For escaped #, add just the # without the escape character
-			currentModifier.WriteByte('#')
-		} else {
-			currentModifier.WriteByte(char)
-		}
-		escaped = false
-	}
-
-	// Add the last modifier if any
-	if currentModifier.Len() > 0 {
-		modifiers = append(modifiers, currentModifier.String())
-	}
-
-	return modifiers
-}
-
-func applyModifier(value, modifierString string) (string, error) {
-	if modifierString == "" {
-		return value, nil
-	}
-
-	// Split the modifier string into individual modifiers
-	modifiers := splitModifiers(modifierString)
-	result := value
-
-	// Apply each modifier in sequence
-	for _, modifier := range modifiers {
-		var err error
-		switch {
-		case modifier == "Reverse":
-			result = Reverse(result)
-		case modifier == "Upper":
-			result = strings.ToUpper(result)
-		case modifier == "Lower":
-			result = strings.ToLower(result)
-		case modifier == "Title":
-			result = cases.Title(language.English).String(strings.ToLower(result))
-		case modifier == "Capitalize":
-			if len(result) > 0 {
-				result = strings.ToUpper(result[:1]) + strings.ToLower(result[1:])
-			}
-		case modifier == "AlternateLower":
-			// Convert to alternating case starting with lowercase (e.g., "Hello" -> "hElLo")
-			runes := []rune(result)
-			for i := range runes {
-				if i%2 == 0 {
-					runes[i] = unicode.ToLower(runes[i])
-				} else {
-					runes[i] = unicode.ToUpper(runes[i])
-				}
-			}
-			result = string(runes)
-		case modifier == "AlternateUpper":
-			// Convert to alternating case starting with uppercase (e.g., "Hello" -> "HeLlO")
-			runes := []rune(result)
-			for i := range runes {
-				if i%2 == 0 {
-					runes[i] = unicode.ToUpper(runes[i])
-				} else {
-					runes[i] = unicode.ToLower(runes[i])
-				}
-			}
-			result = string(runes)
-		case modifier == "LeetBasic":
-			result = leetSpeak(result, LEET_BASIC)
-		case modifier == "LeetBasicPlus":
-			result = leetSpeak(result, LEET_BASIC_PLUS)
-		case strings.HasPrefix(modifier, "Pattern(") && strings.HasSuffix(modifier, ")"):
-			pattern := modifier[8 : len(modifier)-1] // Extract pattern between parentheses
-			result, err = ApplyPattern(result, pattern)
-			if err != nil {
-				return "", fmt.Errorf("error applying pattern modifier: %v", err)
-			}
-		default:
-			return "", fmt.Errorf("unknown modifier: %s", modifier)
-		}
-	}
-
-	return result, nil
-}
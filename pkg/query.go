@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/go-ldap/ldap/v3"
 )
@@ -19,7 +19,19 @@ const (
 	PASS  = 2
 )
 
-func RunLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter, outputFile, outputFormat string, masks []string, pageSize int, silent bool, cacheFile string, noCache bool, forceRefresh bool) {
+// DEFAULT_MAX_ROUNDS is the default maximum number of spray rounds, every round is an output file of its own
+const DEFAULT_MAX_ROUNDS = 1000
+
+// UserAttributes are the LDAP attributes queried for every user. Each of them can be used as a mask placeholder.
+var UserAttributes = []string{"cn", "sn", "givenName", "pwdLastSet", "sAMAccountName", "userPrincipalName", "displayName", "description", "info", "department", "l", "postalCode", "physicalDeliveryOfficeName", "telephoneNumber", "employeeID", "proxyAddresses", "otherTelephone", "memberOf", "servicePrincipalName", "badPwdCount", "lockoutTime", "msDS-ResultantPSO", "msDS-UserPasswordExpiryTimeComputed", "whenCreated", "lastLogonTimestamp", "accountExpires"}
+
+// multiValuedAttributes are printed with all of their values, use #Each to get a candidate per value
+var multiValuedAttributes = []string{"proxyAddresses", "otherTelephone", "memberOf", "servicePrincipalName"}
+
+// dateAttributes are printed as dates instead of raw FILETIME or GeneralizedTime values
+var dateAttributes = []string{"pwdLastSet", "msDS-UserPasswordExpiryTimeComputed", "whenCreated", "lastLogonTimestamp", "accountExpires"}
+
+func RunLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter, outputFile, outputFormat string, masks []*Mask, opts GenOptions, pageSize int, silent bool, cacheFile string, noCache bool, forceRefresh bool) {
 	var searchResult *ldap.SearchResult
 	var attributes []string
 
@@ -32,16 +44,21 @@ func RunLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsernam
 	if !noCache && !forceRefresh {
 		if cachedData, err := LoadLDAPDataFromCache(cacheFile); err == nil {
 			// Check if we need to update the cache based on server information
-			if ShouldUpdateCache(cachedData, ldapServer, ldapPort) {
-				PrintWarning("Server information changed, cache will be updated")
+			if update, reason := ShouldUpdateCache(cachedData, ldapServer, ldapPort); update {
+				PrintWarning(fmt.Sprintf("%s, cache will be updated", reason))
 			} else {
 				PrintInfo("Loading LDAP data from cache")
 				searchResult = &ldap.SearchResult{
 					Entries: ConvertCacheToLDAPEntries(cachedData),
 				}
 				attributes = cachedData.Attributes
-				processResults(searchResult, attributes, silent, outputFile, outputFormat, masks)
-				printPasswordPolicy(cachedData.PasswordPolicy)
+				opts.Policies = PolicySet{Domain: cachedData.PasswordPolicy, PSOs: cachedData.PSOPolicies}
+				opts.Domain = DomainInfo{Domain: ldapDomain}
+				if cachedData.DomainInfo != nil {
+					opts.Domain.NetBIOS, opts.Domain.Forest = cachedData.DomainInfo.NetBIOS, cachedData.DomainInfo.Forest
+				}
+				processResults(searchResult, attributes, silent, outputFile, outputFormat, masks, opts)
+				printPasswordPolicies(opts.Policies)
 				return
 			}
 		} else if !os.IsNotExist(err) {
@@ -50,12 +67,13 @@ func RunLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsernam
 	}
 
 	// If we get here, we need to query LDAP
-	var policy *PasswordPolicy
-	searchResult, _, attributes, policy = performLDAPQuery(ldapServer, ldapPort, ldapS, ntlm, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter, pageSize)
+	var policies PolicySet
+	var domainInfo DomainInfo
+	searchResult, _, attributes, policies, domainInfo = performLDAPQuery(ldapServer, ldapPort, ldapS, ntlm, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter, pageSize)
 
 	// Save results to cache if caching is enabled
 	if !noCache {
-		if err := SaveLDAPDataToCache(searchResult.Entries, ldapOU, ldapFilter, attributes, ldapServer, ldapPort, cacheFile, policy); err != nil {
+		if err := SaveLDAPDataToCache(searchResult.Entries, ldapOU, ldapFilter, attributes, ldapServer, ldapPort, cacheFile, policies, domainInfo); err != nil {
 			PrintWarning(fmt.Sprintf("Error saving cache: %v", err))
 		} else {
 			if forceRefresh {
@@ -66,11 +84,13 @@ func RunLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsernam
 		}
 	}
 
-	processResults(searchResult, attributes, silent, outputFile, outputFormat, masks)
-	printPasswordPolicy(policy)
+	opts.Policies = policies
+	opts.Domain = domainInfo
+	processResults(searchResult, attributes, silent, outputFile, outputFormat, masks, opts)
+	printPasswordPolicies(policies)
 }
 
-func performLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter string, pageSize int) (*ldap.SearchResult, string, []string, *PasswordPolicy) {
+func performLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUsername, ldapPassword, ntlmHash, ldapDomain, ldapOU, ldapFilter string, pageSize int) (*ldap.SearchResult, string, []string, PolicySet, DomainInfo) {
 	PrintInfo("Establishing LDAP Connection")
 	protocol := "ldap"
 	if ldapS {
@@ -148,7 +168,7 @@ func performLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUse
 	searchBase := fmt.Sprintf("%sDC=%s", ou, strings.Join(domainParts, ",DC="))
 	domainBase := fmt.Sprintf("DC=%s", strings.Join(domainParts, ",DC="))
 
-	attributes := []string{"cn", "sn", "givenName", "pwdLastSet", "sAMAccountName", "userPrincipalName", "description", "info", "department", "l", "postalCode", "badPwdCount", "lockoutTime", "msDS-ResultantPSO"}
+	attributes := UserAttributes
 
 	// Search for user accounts
 	searchRequest := ldap.NewSearchRequest(
@@ -171,9 +191,12 @@ func performLDAPQuery(ldapServer string, ldapPort int, ldapS, ntlm bool, ldapUse
 		PrintFatal(err.Error())
 	}
 
-	policy := queryPasswordPolicy(conn, domainBase)
+	policies := PolicySet{
+		Domain: queryPasswordPolicy(conn, domainBase),
+		PSOs:   queryPSOPolicies(conn, domainBase),
+	}
 
-	return searchResult, searchBase, attributes, policy
+	return searchResult, searchBase, attributes, policies, queryDomainInfo(conn, ldapDomain, domainBase)
 }
 
 func queryPasswordPolicy(conn *ldap.Conn, domainBase string) *PasswordPolicy {
@@ -236,12 +259,76 @@ func queryPasswordPolicy(conn *ldap.Conn, domainBase string) *PasswordPolicy {
 	return policy
 }
 
-func printPasswordPolicy(policy *PasswordPolicy) {
+// queryPSOPolicies reads all fine-grained password policies. By default only privileged users can
+// read them, in which case users with a PSO are treated as if the domain policy applied.
+func queryPSOPolicies(conn *ldap.Conn, domainBase string) map[string]*PasswordPolicy {
+	psoAttrs := []string{
+		"cn", "msDS-PasswordSettingsPrecedence", "msDS-MinimumPasswordLength", "msDS-PasswordHistoryLength",
+		"msDS-MaximumPasswordAge", "msDS-MinimumPasswordAge", "msDS-PasswordComplexityEnabled",
+		"msDS-LockoutThreshold", "msDS-LockoutDuration", "msDS-LockoutObservationWindow",
+	}
+	req := ldap.NewSearchRequest(
+		"CN=Password Settings Container,CN=System,"+domainBase,
+		ldap.ScopeSingleLevel,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		"(objectClass=msDS-PasswordSettings)",
+		psoAttrs,
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		PrintWarning(fmt.Sprintf("Could not query fine-grained password policies: %v", err))
+		return nil
+	}
+
+	policies := make(map[string]*PasswordPolicy)
+	for _, entry := range result.Entries {
+		policy := &PasswordPolicy{Name: entry.GetAttributeValue("cn")}
+		policy.Precedence, _ = strconv.Atoi(entry.GetAttributeValue("msDS-PasswordSettingsPrecedence"))
+		policy.MinPwdLength, _ = strconv.Atoi(entry.GetAttributeValue("msDS-MinimumPasswordLength"))
+		policy.PwdHistoryLength, _ = strconv.Atoi(entry.GetAttributeValue("msDS-PasswordHistoryLength"))
+		policy.PwdComplexity = strings.EqualFold(entry.GetAttributeValue("msDS-PasswordComplexityEnabled"), "TRUE")
+		policy.LockoutThreshold, _ = strconv.Atoi(entry.GetAttributeValue("msDS-LockoutThreshold"))
+		if ticks, err := strconv.ParseInt(entry.GetAttributeValue("msDS-MaximumPasswordAge"), 10, 64); err == nil && ticks != 0 {
+			policy.MaxPwdAgeDays = -ticks / 10_000_000 / 86_400
+		}
+		if ticks, err := strconv.ParseInt(entry.GetAttributeValue("msDS-MinimumPasswordAge"), 10, 64); err == nil && ticks != 0 {
+			policy.MinPwdAgeDays = -ticks / 10_000_000 / 86_400
+		}
+		if ticks, err := strconv.ParseInt(entry.GetAttributeValue("msDS-LockoutDuration"), 10, 64); err == nil && ticks != 0 {
+			policy.LockoutDurationMinutes = -ticks / 10_000_000 / 60
+		}
+		if ticks, err := strconv.ParseInt(entry.GetAttributeValue("msDS-LockoutObservationWindow"), 10, 64); err == nil && ticks != 0 {
+			policy.LockoutObservationMinutes = -ticks / 10_000_000 / 60
+		}
+		policies[strings.ToLower(entry.DN)] = policy
+	}
+
+	return policies
+}
+
+// printPasswordPolicies prints the domain password policy followed by all fine-grained password policies
+func printPasswordPolicies(policies PolicySet) {
+	printPasswordPolicy("Password Policy", policies.Domain)
+
+	dns := make([]string, 0, len(policies.PSOs))
+	for dn := range policies.PSOs {
+		dns = append(dns, dn)
+	}
+	sort.Strings(dns)
+	for _, dn := range dns {
+		pso := policies.PSOs[dn]
+		printPasswordPolicy(fmt.Sprintf("Fine-Grained Password Policy %s (precedence %d)", pso.Name, pso.Precedence), pso)
+	}
+}
+
+func printPasswordPolicy(title string, policy *PasswordPolicy) {
 	if policy == nil {
 		return
 	}
 	fmt.Println()
-	PrintInfo("Password Policy")
+	PrintInfo(title)
 	fmt.Printf("  Min. Password Length:         %d\n", policy.MinPwdLength)
 	fmt.Printf("  Password History Length:      %d\n", policy.PwdHistoryLength)
 	if policy.MaxPwdAgeDays == 0 {
@@ -260,7 +347,7 @@ func printPasswordPolicy(policy *PasswordPolicy) {
 	}
 }
 
-func processResults(searchResult *ldap.SearchResult, attributes []string, silent bool, outputFile, outputFormat string, masks []string) {
+func processResults(searchResult *ldap.SearchResult, attributes []string, silent bool, outputFile, outputFormat string, masks []*Mask, opts GenOptions) {
 	fmt.Println()
 	PrintSuccess(fmt.Sprintf("Found %d user accounts", len(searchResult.Entries)))
 
@@ -271,8 +358,15 @@ func processResults(searchResult *ldap.SearchResult, attributes []string, silent
 		for _, entry := range searchResult.Entries {
 			for _, attribute := range attributes {
 				value := entry.GetAttributeValue(attribute)
-				if attribute == "pwdLastSet" {
-					value = convertTime(value)
+				for _, multiValued := range multiValuedAttributes {
+					if attribute == multiValued {
+						value = strings.Join(entry.GetAttributeValues(attribute), ", ")
+					}
+				}
+				for _, dateAttribute := range dateAttributes {
+					if attribute == dateAttribute {
+						value = convertTime(entry, attribute)
+					}
 				}
 				fmt.Printf("%s: %s\n", attribute, value)
 			}
@@ -281,60 +375,74 @@ func processResults(searchResult *ldap.SearchResult, attributes []string, silent
 	}
 
 	// Generate passwords for each mask
-	for _, mask := range masks {
-		var file *os.File
-		var file2 *os.File
-		var path string
-		var path2 string
-
-		if outputFile != "" {
-			if strings.ToLower(outputFormat) == "kerbrute" {
-				file, path = createFile(outputFile, COMBO)
-			} else if strings.ToLower(outputFormat) == "netexec" {
-				file, path = createFile(outputFile, USER)
-				file2, path2 = createFile(outputFile, PASS)
+	dropped := make([][]droppedCandidate, len(masks))
+	perMask := make([][][]string, len(masks))
+	for m, mask := range masks {
+		candidates := make([][]string, len(searchResult.Entries))
+		skipped, capped := 0, 0
+		for i, entry := range searchResult.Entries {
+			candidates[i] = generatePWs(entry, mask, opts)
+			if len(candidates[i]) == 0 {
+				skipped++
+			}
+			if opts.PolicyFilter {
+				var userDropped []droppedCandidate
+				candidates[i], userDropped = filterCompliant(entry, opts.Policies.PolicyFor(entry), candidates[i])
+				dropped[m] = append(dropped[m], userDropped...)
+			}
+			if opts.MaxCandidates > 0 && len(candidates[i]) > opts.MaxCandidates {
+				candidates[i] = candidates[i][:opts.MaxCandidates]
+				capped++
 			}
 		}
+		perMask[m] = candidates
 
-		if !silent {
+		if skipped > 0 {
 			fmt.Println()
-			if len(masks) > 1 {
-				PrintInfo(fmt.Sprintf("Pw spray combos (mask: %s)", mask))
-			} else {
-				PrintInfo("Pw spray combos")
-			}
+			PrintWarning(fmt.Sprintf("Skipped %d of %d user(s) with unresolvable placeholders (mask: %s)", skipped, len(searchResult.Entries), mask.Raw))
 		}
-		for _, entry := range searchResult.Entries {
-			username := entry.GetAttributeValue("sAMAccountName")
-			password := generatePW(entry, mask)
-			combo := fmt.Sprintf("%s:%s", username, password)
-			if !silent {
-				fmt.Println(combo)
-			}
-			if strings.ToLower(outputFormat) == "kerbrute" && file != nil {
-				appendToFile(file, combo)
-			} else if strings.ToLower(outputFormat) == "netexec" && file != nil && file2 != nil {
-				appendToFile(file, username)
-				appendToFile(file2, password)
-			}
+		if capped > 0 {
+			fmt.Println()
+			PrintWarning(fmt.Sprintf("Capped the candidates of %d user(s) at %d (mask: %s)", capped, opts.MaxCandidates, mask.Raw))
+		}
+		if len(dropped[m]) > 0 {
+			printDroppedSummary(mask.Raw, dropped[m])
 		}
+	}
 
-		if file != nil {
-			fmt.Println()
-			if strings.ToLower(outputFormat) == "kerbrute" {
-				PrintSuccess("User:Pass spray list written to " + path)
-			} else {
-				PrintSuccess("User spray list written to " + path)
+	// Merge the masks, so that every user gets each password once and the most likely ones first
+	candidates, duplicates := rankCandidates(masks, perMask, len(searchResult.Entries))
+	if duplicates > 0 {
+		fmt.Println()
+		PrintInfo(fmt.Sprintf("Removed %d candidate(s) that other masks already generated for the same user", duplicates))
+	}
+	if opts.MaxPerUser > 0 {
+		capped := 0
+		for i := range candidates {
+			if len(candidates[i]) > opts.MaxPerUser {
+				candidates[i] = candidates[i][:opts.MaxPerUser]
+				capped++
 			}
-			file.Close()
 		}
-
-		if file2 != nil {
+		if capped > 0 {
 			fmt.Println()
-			PrintSuccess("Pw spray list written to " + path2)
-			file2.Close()
+			PrintWarning(fmt.Sprintf("Capped the candidates of %d user(s) at %d over all masks", capped, opts.MaxPerUser))
 		}
 	}
+	rounds := buildSprayRounds(searchResult.Entries, candidates)
+	if opts.MaxRounds > 0 && len(rounds) > opts.MaxRounds {
+		PrintFatal(fmt.Sprintf("%d spray rounds, each in its own output file, are more than --max-rounds %d. Use --max-candidates or --max-per-user to keep fewer candidates per user", len(rounds), opts.MaxRounds))
+	}
+	for i, round := range rounds {
+		title := "Pw spray combos"
+		if len(rounds) > 1 {
+			title = fmt.Sprintf("%s [round %d/%d]", title, i+1, len(rounds))
+		}
+		writeSprayRound(round, title, silent, outputFile, outputFormat)
+	}
+	if opts.PolicyReport != "" {
+		writePolicyReport(opts.PolicyReport, masks, dropped)
+	}
 
 	// Warn about locked accounts and accounts with bad password attempts
 	var lockedUsers []string
@@ -381,6 +489,78 @@ func processResults(searchResult *ldap.SearchResult, attributes []string, silent
 	}
 }
 
+// sprayCombo is a single user:password attempt
+type sprayCombo struct {
+	username string
+	password string
+}
+
+// buildSprayRounds distributes the candidates so that every round contains at most one
+// password per user. Round n holds the n-th candidate of every user that has one.
+func buildSprayRounds(entries []*ldap.Entry, candidates [][]string) [][]sprayCombo {
+	var rounds [][]sprayCombo
+	for i, entry := range entries {
+		username := entry.GetAttributeValue("sAMAccountName")
+		for n, password := range candidates[i] {
+			if n == len(rounds) {
+				rounds = append(rounds, nil)
+			}
+			rounds[n] = append(rounds[n], sprayCombo{username: username, password: password})
+		}
+	}
+	return rounds
+}
+
+// writeSprayRound prints a round and writes it to a new output file, so that spray runs every round separately
+func writeSprayRound(round []sprayCombo, title string, silent bool, outputFile, outputFormat string) {
+	var file *os.File
+	var file2 *os.File
+	var path string
+	var path2 string
+
+	if outputFile != "" {
+		if strings.ToLower(outputFormat) == "kerbrute" {
+			file, path = createFile(outputFile, COMBO)
+		} else if strings.ToLower(outputFormat) == "netexec" {
+			file, path = createFile(outputFile, USER)
+			file2, path2 = createFile(outputFile, PASS)
+		}
+	}
+
+	if !silent {
+		fmt.Println()
+		PrintInfo(title)
+	}
+	for _, c := range round {
+		combo := fmt.Sprintf("%s:%s", c.username, c.password)
+		if !silent {
+			fmt.Println(combo)
+		}
+		if strings.ToLower(outputFormat) == "kerbrute" && file != nil {
+			appendToFile(file, combo)
+		} else if strings.ToLower(outputFormat) == "netexec" && file != nil && file2 != nil {
+			appendToFile(file, c.username)
+			appendToFile(file2, c.password)
+		}
+	}
+
+	if file != nil {
+		fmt.Println()
+		if strings.ToLower(outputFormat) == "kerbrute" {
+			PrintSuccess("User:Pass spray list written to " + path)
+		} else {
+			PrintSuccess("User spray list written to " + path)
+		}
+		file.Close()
+	}
+
+	if file2 != nil {
+		fmt.Println()
+		PrintSuccess("Pw spray list written to " + path2)
+		file2.Close()
+	}
+}
+
 func buildMaskOutputPath(path string, maskIndex int) string {
 	dir := filepath.Dir(path)
 	base := filepath.Base(path)
@@ -452,17 +632,12 @@ func CreateOutputFile(path string) (*os.File, string) {
 	return createFile(path, COMBO)
 }
 
-func convertTime(pwdLastSet string) string {
-	if pwdLastSet == "" {
+func convertTime(entry *ldap.Entry, attribute string) string {
+	t, ok := attributeToTime(entry, attribute)
+	if !ok {
 		return ""
 	}
-	// Convert pwdLastSet value to a time.Time object
-	interval, err := strconv.ParseInt(pwdLastSet, 10, 64)
-	if err != nil {
-		PrintFatal(err.Error())
-	}
-	lastSetTime := time.Unix(0, (interval-116444736000000000)*100)
 
-	// Format lastSetTime as a human-readable string
-	return lastSetTime.Format("2006-01-02")
+	// Format the time as a human-readable string
+	return t.Format("2006-01-02")
 }
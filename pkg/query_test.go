@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestBuildSprayRounds(t *testing.T) {
+	entries := []*ldap.Entry{
+		ldap.NewEntry("CN=a", map[string][]string{"sAMAccountName": {"alice"}}),
+		ldap.NewEntry("CN=b", map[string][]string{"sAMAccountName": {"bob"}}),
+		ldap.NewEntry("CN=c", map[string][]string{"sAMAccountName": {"carol"}}),
+	}
+	rounds := buildSprayRounds(entries, [][]string{{"a1", "a2", "a3"}, nil, {"c1"}})
+	want := [][]sprayCombo{
+		{{"alice", "a1"}, {"carol", "c1"}},
+		{{"alice", "a2"}},
+		{{"alice", "a3"}},
+	}
+	if !reflect.DeepEqual(rounds, want) {
+		t.Errorf("buildSprayRounds = %v, want %v", rounds, want)
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	masks := []*Mask{{Raw: "first", Weight: 1}, {Raw: "second", Weight: 1}}
+	perMask := [][][]string{
+		{{"Summer1", "Summer2", "Summer3"}},
+		{{"Winter1", "Summer2"}},
+	}
+	ranked, duplicates := rankCandidates(masks, perMask, 1)
+	want := []string{"Summer1", "Winter1", "Summer2", "Summer3"}
+	if !reflect.DeepEqual(ranked[0], want) || duplicates != 1 {
+		t.Errorf("rankCandidates = %q, %d duplicates, want %q, 1 duplicate", ranked[0], duplicates, want)
+	}
+}
+
+func TestShouldUpdateCache(t *testing.T) {
+	current := &CachedLDAPData{LDAPServer: "dc", LDAPPort: 389, Attributes: UserAttributes, DomainInfo: &DomainInfo{}}
+	if update, reason := ShouldUpdateCache(current, "dc", 389); update {
+		t.Errorf("ShouldUpdateCache of a current cache = true (%s), want false", reason)
+	}
+	tests := map[string]*CachedLDAPData{
+		"server changed":         {LDAPServer: "other", LDAPPort: 389, Attributes: UserAttributes, DomainInfo: &DomainInfo{}},
+		"attributes missing":     {LDAPServer: "dc", LDAPPort: 389, Attributes: []string{"cn", "sn"}, DomainInfo: &DomainInfo{}},
+		"domain info missing":    {LDAPServer: "dc", LDAPPort: 389, Attributes: UserAttributes},
+		"written without fields": {LDAPServer: "dc", LDAPPort: 389},
+	}
+	for name, cached := range tests {
+		if update, _ := ShouldUpdateCache(cached, "dc", 389); !update {
+			t.Errorf("ShouldUpdateCache with %s = false, want true", name)
+		}
+	}
+}
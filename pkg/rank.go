@@ -0,0 +1,49 @@
+package pkg
+
+import "sort"
+
+// rankedCandidate is a candidate of a user together with the mask and position it was generated at
+type rankedCandidate struct {
+	password string
+	score    float64
+	mask     int
+	index    int
+}
+
+// rankCandidates merges the candidates of all masks per user. The n-th candidate of a mask
+// scores weight/n, so the first candidates of heavy masks come first and the alternatives of a
+// single mask do not push all other masks back. Ties keep the mask file order. Every password
+// is kept once per user, at its best rank. perMask is indexed by mask, then by user.
+func rankCandidates(masks []*Mask, perMask [][][]string, users int) ([][]string, int) {
+	merged := make([][]string, users)
+	duplicates := 0
+	for user := 0; user < users; user++ {
+		var ranked []rankedCandidate
+		for m, mask := range masks {
+			weight := mask.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			for index, password := range perMask[m][user] {
+				ranked = append(ranked, rankedCandidate{password: password, score: weight / float64(index+1), mask: m, index: index})
+			}
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if ranked[i].score != ranked[j].score {
+				return ranked[i].score > ranked[j].score
+			}
+			return ranked[i].mask < ranked[j].mask
+		})
+
+		seen := make(map[string]bool, len(ranked))
+		for _, candidate := range ranked {
+			if seen[candidate.password] {
+				duplicates++
+				continue
+			}
+			seen[candidate.password] = true
+			merged[user] = append(merged[user], candidate.password)
+		}
+	}
+	return merged, duplicates
+}
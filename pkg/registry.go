@@ -0,0 +1,226 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ModifierFunc applies a modifier to a value. Expanding modifiers return several values,
+// returning no value drops the candidate like an empty attribute.
+type ModifierFunc func(value string, args ModifierArgs) ([]string, error)
+
+// ModifierArgs are the arguments of a modifier call, e.g. "0,6" for #PadLeft(0,6)
+type ModifierArgs struct {
+	// Raw is the argument list as written in the mask, including backslash escapes
+	Raw string
+	// Present is false for calls without parentheses
+	Present bool
+}
+
+// List splits the arguments at unescaped commas and trims them
+func (a ModifierArgs) List() []string {
+	if !a.Present {
+		return nil
+	}
+	return splitArgs(a.Raw)
+}
+
+// Ints parses exactly n comma-separated integer arguments
+func (a ModifierArgs) Ints(n int) ([]int, error) {
+	return parseIntArgs(a.Raw, n)
+}
+
+// Int parses the n-th argument (0-based) as an integer
+func (a ModifierArgs) Int(n int) (int, error) {
+	list := a.List()
+	if n >= len(list) {
+		return 0, fmt.Errorf("missing argument %d", n+1)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(list[n]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer argument %q", strings.TrimSpace(list[n]))
+	}
+	return value, nil
+}
+
+// ModifierSpec describes the arguments and the help of a modifier
+type ModifierSpec struct {
+	// MinArgs and MaxArgs limit the number of comma-separated arguments, 0 and 0 for none
+	MinArgs, MaxArgs int
+	// RawArgs marks modifiers whose whole argument list is a single free-form argument like a Pattern() rule
+	RawArgs bool
+	// Validate checks the arguments during mask validation, before any LDAP query
+	Validate func(args ModifierArgs) error
+	// Help is shown by gen --help, one line per syntax
+	Help []ModifierHelp
+}
+
+// ModifierHelp is a single help line like "#First(n)" with its description
+type ModifierHelp struct {
+	Syntax string
+	Text   string
+}
+
+type registeredModifier struct {
+	name string
+	fn   ModifierFunc
+	spec ModifierSpec
+}
+
+// PlaceholderContext gives placeholder resolvers access to the user and the generation options
+type PlaceholderContext struct {
+	Entry   *ldap.Entry
+	Options GenOptions
+	// Policy is the password policy of the user, nil if unknown
+	Policy *PasswordPolicy
+}
+
+// PlaceholderResolver returns the value of a placeholder for a user, "" if it has no value
+type PlaceholderResolver func(ctx PlaceholderContext) string
+
+type registeredPlaceholder struct {
+	name     string
+	resolver PlaceholderResolver
+	help     string
+}
+
+var (
+	modifierRegistry    = map[string]*registeredModifier{}
+	modifierOrder       []string
+	placeholderRegistry = map[string]*registeredPlaceholder{}
+	placeholderOrder    []string
+)
+
+// RegisterModifier makes a modifier available as #name in masks. Modifier names are case-sensitive.
+func RegisterModifier(name string, fn ModifierFunc, spec ModifierSpec) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isModifierNameRune(r) }) >= 0 {
+		return fmt.Errorf("invalid modifier name %q, use letters and digits", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("modifier %s: missing function", name)
+	}
+	if spec.MinArgs < 0 || spec.MaxArgs < spec.MinArgs {
+		return fmt.Errorf("modifier %s: invalid argument range %d to %d", name, spec.MinArgs, spec.MaxArgs)
+	}
+	if _, ok := modifierRegistry[name]; ok {
+		return fmt.Errorf("modifier %s is already registered", name)
+	}
+	modifierRegistry[name] = &registeredModifier{name: name, fn: fn, spec: spec}
+	modifierOrder = append(modifierOrder, name)
+	return nil
+}
+
+// RegisterPlaceholder makes a placeholder available as {name} in masks. Placeholder names are
+// case-insensitive and must not shadow a queried attribute or a date placeholder.
+func RegisterPlaceholder(name string, resolver PlaceholderResolver, help string) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isModifierNameRune(r) && r != '_' && r != '-' }) >= 0 {
+		return fmt.Errorf("invalid placeholder name %q, use letters, digits, '_' and '-'", name)
+	}
+	if resolver == nil {
+		return fmt.Errorf("placeholder %s: missing resolver", name)
+	}
+	if _, ok := lookupPlaceholder(name); ok {
+		return fmt.Errorf("placeholder %s is already registered", name)
+	}
+	if isDatePlaceholder(name) || isAttribute(name) {
+		return fmt.Errorf("placeholder %s would shadow a built-in placeholder", name)
+	}
+	key := strings.ToLower(name)
+	placeholderRegistry[key] = &registeredPlaceholder{name: name, resolver: resolver, help: help}
+	placeholderOrder = append(placeholderOrder, key)
+	return nil
+}
+
+// mustRegisterModifier registers a built-in modifier
+func mustRegisterModifier(name string, fn ModifierFunc, spec ModifierSpec) {
+	if err := RegisterModifier(name, fn, spec); err != nil {
+		panic(err)
+	}
+}
+
+func lookupPlaceholder(name string) (*registeredPlaceholder, bool) {
+	placeholder, ok := placeholderRegistry[strings.ToLower(name)]
+	return placeholder, ok
+}
+
+// ModifierHelpLines returns the help of all modifiers in registration order
+func ModifierHelpLines() []string {
+	var lines []string
+	for _, name := range modifierOrder {
+		for _, help := range modifierRegistry[name].spec.Help {
+			lines = append(lines, fmt.Sprintf("- %-18s: %s", help.Syntax, help.Text))
+		}
+	}
+	return lines
+}
+
+// PlaceholderHelpLines returns the help of all registered placeholders in registration order
+func PlaceholderHelpLines() []string {
+	var lines []string
+	for _, key := range placeholderOrder {
+		placeholder := placeholderRegistry[key]
+		lines = append(lines, fmt.Sprintf("- %-18s: %s", "{"+placeholder.name+"}", placeholder.help))
+	}
+	return lines
+}
+
+// validateModifier checks the name and the arguments of a modifier call
+func validateModifier(call ModifierCall) error {
+	modifier, ok := modifierRegistry[call.Name]
+	if !ok {
+		for _, name := range modifierOrder {
+			if strings.EqualFold(name, call.Name) {
+				return fmt.Errorf("unknown modifier %q, did you mean %q?", call.Name, name)
+			}
+		}
+		return fmt.Errorf("unknown modifier %q", call.Name)
+	}
+	spec := modifier.spec
+	if spec.MaxArgs == 0 && call.HasArgs {
+		return fmt.Errorf("modifier %s does not take arguments", call.Name)
+	}
+	if spec.MinArgs > 0 && !call.HasArgs {
+		return fmt.Errorf("modifier %s requires arguments", call.Name)
+	}
+	if !call.HasArgs {
+		return nil
+	}
+	args := ModifierArgs{Raw: call.Args, Present: true}
+	if count := len(args.List()); !spec.RawArgs && (count < spec.MinArgs || count > spec.MaxArgs) {
+		if spec.MinArgs == spec.MaxArgs {
+			return fmt.Errorf("modifier %s expects %d argument(s), got %d", call.Name, spec.MinArgs, count)
+		}
+		return fmt.Errorf("modifier %s expects %d to %d arguments, got %d", call.Name, spec.MinArgs, spec.MaxArgs, count)
+	}
+	if spec.Validate != nil {
+		if err := spec.Validate(args); err != nil {
+			return fmt.Errorf("modifier %s: %v", call.Name, err)
+		}
+	}
+	return nil
+}
+
+// applyModifiers applies the modifiers in sequence. Expanding modifiers return several values,
+// every following modifier is applied to each of them.
+func applyModifiers(value string, modifiers []ModifierCall) ([]string, error) {
+	results := []string{value}
+	for _, call := range modifiers {
+		modifier, ok := modifierRegistry[call.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown modifier: %s", call.Name)
+		}
+		var next []string
+		for _, result := range results {
+			values, err := modifier.fn(result, ModifierArgs{Raw: call.Args, Present: call.HasArgs})
+			if err != nil {
+				return nil, fmt.Errorf("error applying %s modifier: %v", call.Name, err)
+			}
+			next = append(next, values...)
+		}
+		results = uniqueStrings(next)
+	}
+	return results, nil
+}
@@ -0,0 +1,374 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Rule is a parsed hashcat rule, i.e. a sequence of rule functions applied to a candidate
+type Rule struct {
+	Raw   string
+	funcs []ruleFunc
+}
+
+// ruleFunc is a single rule function with its decoded positions (n, m) and characters (x, y)
+type ruleFunc struct {
+	op   rune
+	n, m int
+	x, y rune
+}
+
+// RuleError is an invalid rule in a rule file
+type RuleError struct {
+	Line int
+	Rule string
+	Msg  string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Rule, e.Msg)
+}
+
+// ruleParams lists the parameters of every supported rule function: N is a position
+// (0-9, A-Z for 10-35) and X a character
+var ruleParams = map[rune]string{
+	// Modifications
+	':': "", 'l': "", 'u': "", 'c': "", 'C': "", 't': "", 'T': "N", 'r': "", 'd': "", 'p': "N",
+	'f': "", '{': "", '}': "", '$': "X", '^': "X", '[': "", ']': "", 'D': "N", 'x': "NN",
+	'O': "NN", 'i': "NX", 'o': "NX", '\'': "N", 's': "XX", '@': "X", 'z': "N", 'Z': "N",
+	'q': "", 'k': "", 'K': "", '*': "NN", 'L': "N", 'R': "N", '+': "N", '-': "N", '.': "N",
+	',': "N", 'y': "N", 'Y': "N", 'E': "", 'e': "X", '3': "NX",
+	// Rejections
+	'<': "N", '>': "N", '_': "N", '!': "X", '/': "X", '(': "X", ')': "X", '=': "NX", '%': "NX",
+}
+
+// ParseRule parses a single hashcat rule like "c $1 $!" or "sa@ ^#"
+func ParseRule(raw string) (Rule, error) {
+	rule := Rule{Raw: raw}
+	input := []rune(raw)
+	for i := 0; i < len(input); i++ {
+		op := input[i]
+		if op == ' ' || op == '\t' {
+			continue
+		}
+		params, ok := ruleParams[op]
+		if !ok {
+			return Rule{}, fmt.Errorf("unsupported rule function %q at position %d", op, i+1)
+		}
+
+		f := ruleFunc{op: op}
+		positions, chars := 0, 0
+		for _, param := range params {
+			i++
+			if i >= len(input) {
+				return Rule{}, fmt.Errorf("rule function %q at position %d is missing parameters", op, i)
+			}
+			switch param {
+			case 'N':
+				pos, ok := rulePosition(input[i])
+				if !ok {
+					return Rule{}, fmt.Errorf("invalid position %q at position %d, expected 0-9 or A-Z", input[i], i+1)
+				}
+				if positions == 0 {
+					f.n = pos
+				} else {
+					f.m = pos
+				}
+				positions++
+			case 'X':
+				if chars == 0 {
+					f.x = input[i]
+				} else {
+					f.y = input[i]
+				}
+				chars++
+			}
+		}
+		rule.funcs = append(rule.funcs, f)
+	}
+	if len(rule.funcs) == 0 {
+		return Rule{}, fmt.Errorf("empty rule")
+	}
+	return rule, nil
+}
+
+// LoadRuleFile reads a hashcat rule file. Empty lines and lines starting with # are ignored,
+// every invalid rule is returned as a RuleError.
+func LoadRuleFile(path string) ([]Rule, []error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open rule file: %w", err)
+	}
+	defer f.Close()
+
+	var rules []Rule
+	var ruleErrors []error
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			ruleErrors = append(ruleErrors, &RuleError{Line: lineNumber, Rule: line, Msg: err.Error()})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading rule file: %w", err)
+	}
+	return rules, ruleErrors, nil
+}
+
+// applyRules applies every rule to every candidate, rule by rule, so that the first rules
+// of a file end up in the first spray rounds. The candidates themselves are only kept if
+// the rules contain the no-op rule ":".
+func applyRules(candidates []string, rules []Rule) []string {
+	if len(rules) == 0 {
+		return candidates
+	}
+	var results []string
+	for _, rule := range rules {
+		for _, candidate := range candidates {
+			if result, ok := rule.Apply(candidate); ok && result != "" {
+				results = append(results, result)
+			}
+		}
+	}
+	return uniqueStrings(results)
+}
+
+// Apply applies the rule to a word. ok is false if a rejection function rejects the word.
+// Like hashcat, functions with positions outside of the word leave it unchanged.
+func (r Rule) Apply(word string) (string, bool) {
+	w := []rune(word)
+	for _, f := range r.funcs {
+		var ok bool
+		if w, ok = f.apply(w); !ok {
+			return "", false
+		}
+	}
+	return string(w), true
+}
+
+func (f ruleFunc) apply(w []rune) ([]rune, bool) {
+	n, m := f.n, f.m
+	switch f.op {
+	case ':':
+	case 'l':
+		w = []rune(strings.ToLower(string(w)))
+	case 'u':
+		w = []rune(strings.ToUpper(string(w)))
+	case 'c':
+		w = []rune(capitalize(string(w)))
+	case 'C':
+		w = []rune(strings.ToUpper(string(w)))
+		if len(w) > 0 {
+			w[0] = unicode.ToLower(w[0])
+		}
+	case 't':
+		for i := range w {
+			w[i] = toggleCase(w[i])
+		}
+	case 'T':
+		if n < len(w) {
+			w[n] = toggleCase(w[n])
+		}
+	case 'r':
+		w = []rune(Reverse(string(w)))
+	case 'd':
+		w = append(w, w...)
+	case 'p':
+		w = []rune(strings.Repeat(string(w), n+1))
+	case 'f':
+		w = append(w, []rune(Reverse(string(w)))...)
+	case '{':
+		if len(w) > 0 {
+			w = append(w[1:], w[0])
+		}
+	case '}':
+		if len(w) > 0 {
+			w = append([]rune{w[len(w)-1]}, w[:len(w)-1]...)
+		}
+	case '$':
+		w = append(w, f.x)
+	case '^':
+		w = append([]rune{f.x}, w...)
+	case '[':
+		if len(w) > 0 {
+			w = w[1:]
+		}
+	case ']':
+		if len(w) > 0 {
+			w = w[:len(w)-1]
+		}
+	case 'D':
+		if n < len(w) {
+			w = append(w[:n:n], w[n+1:]...)
+		}
+	case 'x':
+		if n+m <= len(w) {
+			w = w[n : n+m]
+		}
+	case 'O':
+		if n+m <= len(w) {
+			w = append(w[:n:n], w[n+m:]...)
+		}
+	case 'i':
+		if n <= len(w) {
+			w = append(w[:n:n], append([]rune{f.x}, w[n:]...)...)
+		}
+	case 'o':
+		if n < len(w) {
+			w[n] = f.x
+		}
+	case '\'':
+		if n < len(w) {
+			w = w[:n]
+		}
+	case 's':
+		for i := range w {
+			if w[i] == f.x {
+				w[i] = f.y
+			}
+		}
+	case '@':
+		w = []rune(strings.ReplaceAll(string(w), string(f.x), ""))
+	case 'z':
+		if len(w) > 0 {
+			w = append([]rune(strings.Repeat(string(w[0]), n)), w...)
+		}
+	case 'Z':
+		if len(w) > 0 {
+			w = append(w, []rune(strings.Repeat(string(w[len(w)-1]), n))...)
+		}
+	case 'q':
+		doubled := make([]rune, 0, 2*len(w))
+		for _, r := range w {
+			doubled = append(doubled, r, r)
+		}
+		w = doubled
+	case 'k':
+		if len(w) >= 2 {
+			w[0], w[1] = w[1], w[0]
+		}
+	case 'K':
+		if len(w) >= 2 {
+			w[len(w)-1], w[len(w)-2] = w[len(w)-2], w[len(w)-1]
+		}
+	case '*':
+		if n < len(w) && m < len(w) {
+			w[n], w[m] = w[m], w[n]
+		}
+	case 'L':
+		if n < len(w) {
+			w[n] <<= 1
+		}
+	case 'R':
+		if n < len(w) {
+			w[n] >>= 1
+		}
+	case '+':
+		if n < len(w) {
+			w[n]++
+		}
+	case '-':
+		if n < len(w) {
+			w[n]--
+		}
+	case '.':
+		if n+1 < len(w) {
+			w[n] = w[n+1]
+		}
+	case ',':
+		if n > 0 && n < len(w) {
+			w[n] = w[n-1]
+		}
+	case 'y':
+		if n <= len(w) {
+			w = append(append([]rune{}, w[:n]...), w...)
+		}
+	case 'Y':
+		if n <= len(w) {
+			w = append(w, append([]rune{}, w[len(w)-n:]...)...)
+		}
+	case 'E', 'e':
+		separator := ' '
+		if f.op == 'e' {
+			separator = f.x
+		}
+		w = []rune(strings.ToLower(string(w)))
+		for i := range w {
+			if i == 0 || w[i-1] == separator {
+				w[i] = unicode.ToUpper(w[i])
+			}
+		}
+	case '3':
+		// Toggle the case of the character after the n-th occurrence of x
+		count := 0
+		for i := range w {
+			if w[i] != f.x {
+				continue
+			}
+			if count == n {
+				if i+1 < len(w) {
+					w[i+1] = toggleCase(w[i+1])
+				}
+				break
+			}
+			count++
+		}
+	case '<':
+		return w, len(w) <= n
+	case '>':
+		return w, len(w) >= n
+	case '_':
+		return w, len(w) == n
+	case '!':
+		return w, !containsRune(w, f.x)
+	case '/':
+		return w, containsRune(w, f.x)
+	case '(':
+		return w, len(w) > 0 && w[0] == f.x
+	case ')':
+		return w, len(w) > 0 && w[len(w)-1] == f.x
+	case '=':
+		return w, n < len(w) && w[n] == f.x
+	case '%':
+		count := 0
+		for _, r := range w {
+			if r == f.x {
+				count++
+			}
+		}
+		return w, count >= n
+	}
+	return w, true
+}
+
+// rulePosition decodes a hashcat position: 0-9 and A-Z for 10-35
+func rulePosition(r rune) (int, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0'), true
+	case r >= 'A' && r <= 'Z':
+		return int(r-'A') + 10, true
+	}
+	return 0, false
+}
+
+func containsRune(w []rune, x rune) bool {
+	for _, r := range w {
+		if r == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// TEMPLATE_PREFIX marks masks that are Go text/templates instead of brace masks, e.g.
+// tmpl:{{if eq .department "IT"}}Admin{{else}}Welcome{{end}}{{date "YYYY"}}!
+const TEMPLATE_PREFIX = "tmpl:"
+
+// templateArgEscaper escapes template function arguments so that they reach a modifier as a single argument
+var templateArgEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`)
+
+// isTemplateMask reports whether a mask is a text/template mask
+func isTemplateMask(mask string) bool {
+	return strings.HasPrefix(mask, TEMPLATE_PREFIX)
+}
+
+// parseTemplateMask parses a text/template mask. The template is executed with the user's attributes
+// as data, every non-empty line of the output is a candidate. name is the template name shown in errors.
+func parseTemplateMask(raw, name, text string) (*Mask, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(templateFuncs(nil)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", strings.TrimPrefix(err.Error(), "template: "))
+	}
+	m := &Mask{Raw: raw, Template: tmpl}
+	if err := validateTemplate(m); err != nil {
+		return nil, err
+	}
+	rewritePrintedFields(m)
+	return m, nil
+}
+
+// LoadTemplateMask reads a template mask from a file, the whole file is a single template
+func LoadTemplateMask(path string) (*Mask, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open mask template: %w", err)
+	}
+	return parseTemplateMask(TEMPLATE_PREFIX+path, filepath.Base(path), string(content))
+}
+
+// templateFuncs returns the functions available in template masks. Functions that depend on the
+// user are only stubs without a context, they are replaced before every execution.
+func templateFuncs(ctx *maskContext) template.FuncMap {
+	funcs := template.FuncMap{}
+	for _, name := range modifierOrder {
+		funcs[name] = func(args ...interface{}) (string, error) {
+			values, err := applyTemplateModifier(name, args)
+			if err != nil || len(values) == 0 {
+				return "", err
+			}
+			return values[0], nil
+		}
+	}
+	funcs["expand"] = func(name string, args ...interface{}) ([]string, error) {
+		return applyTemplateModifier(name, args)
+	}
+
+	// Functions that need the user. Empty values are handled like empty placeholders of brace masks.
+	funcs["attr"] = func(name string) (string, error) {
+		return ctx.templateValue(ctx.entry.GetEqualFoldAttributeValue(name), false)
+	}
+	funcs["values"] = func(name string) []string {
+		return ctx.entry.GetEqualFoldAttributeValues(name)
+	}
+	funcs["date"] = func(name string) (string, error) {
+		spec, ok := parseDatePlaceholder(name)
+		if !ok {
+			return "", fmt.Errorf("unknown date placeholder %q", name)
+		}
+		return ctx.templateValue(ctx.resolveDate(spec), true)
+	}
+	funcs["var"] = func(name string) (string, error) {
+		return ctx.templateValue(ctx.resolveVariable(name), false)
+	}
+	funcs["policy"] = func() *PasswordPolicy {
+		return ctx.policy
+	}
+	return funcs
+}
+
+// errEmptyPlaceholder stops a template for a user whose attr, date or var is empty if the
+// OnEmpty option skips such users
+var errEmptyPlaceholder = errors.New("empty placeholder")
+
+// templateValue applies the OnEmpty option to the value of an attr, date or var call
+func (ctx *maskContext) templateValue(value string, isDate bool) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	if value, ok := ctx.emptyValue(isDate); ok {
+		return value, nil
+	}
+	return "", errEmptyPlaceholder
+}
+
+// applyTemplateModifier applies a modifier called from a template. The last argument is the value,
+// so modifiers can be used in pipelines like {{.sn | PadRight "!" 6}}.
+func applyTemplateModifier(name string, args []interface{}) ([]string, error) {
+	modifier, ok := modifierRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown modifier %q", name)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("modifier %s: missing value", name)
+	}
+	var parts []string
+	for _, arg := range args[:len(args)-1] {
+		if modifier.spec.RawArgs {
+			parts = append(parts, fmt.Sprint(arg))
+		} else {
+			parts = append(parts, templateArgEscaper.Replace(fmt.Sprint(arg)))
+		}
+	}
+	call := ModifierCall{Name: name, Args: strings.Join(parts, ","), HasArgs: len(parts) > 0}
+	if err := validateModifier(call); err != nil {
+		return nil, err
+	}
+	return applyModifiers(fmt.Sprint(args[len(args)-1]), []ModifierCall{call})
+}
+
+// validateTemplate checks the attribute fields, date placeholders and variables of a template
+// as far as they are known before it is executed
+func validateTemplate(m *Mask) error {
+	var err error
+	for _, tmpl := range m.Template.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		// The dot of templates called with {{template}} is only known at runtime
+		walkTemplate(tmpl.Tree.Root, tmpl == m.Template, func(node parse.Node, onData bool) bool {
+			switch n := node.(type) {
+			case *parse.FieldNode:
+				if onData {
+					err = checkTemplateField(n.Ident[0])
+				}
+			case *parse.CommandNode:
+				name, arg, ok := templateCallArg(n)
+				if ok && name == "date" && !isDatePlaceholder(arg) {
+					err = fmt.Errorf("unknown date placeholder %q", arg)
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewritePrintedFields replaces fields that are printed, like {{.givenName}} or {{.sn | Upper}},
+// with {{attr "givenName"}} calls, so their empty values are handled by the OnEmpty option.
+// Fields in conditions like {{if .department}} and in variable declarations keep the raw value.
+func rewritePrintedFields(m *Mask) {
+	for _, tmpl := range m.Template.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		walkTemplate(tmpl.Tree.Root, tmpl == m.Template, func(node parse.Node, onData bool) bool {
+			if n, ok := node.(*parse.ActionNode); ok && onData && len(n.Pipe.Decl) == 0 {
+				rewriteFieldArgs(tmpl.Tree, n.Pipe)
+			}
+			return true
+		})
+	}
+}
+
+// rewriteFieldArgs replaces the single-name fields of a pipeline and its parenthesized pipelines with attr calls
+func rewriteFieldArgs(tree *parse.Tree, pipe *parse.PipeNode) {
+	for _, cmd := range pipe.Cmds {
+		for i, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if len(a.Ident) != 1 {
+					continue
+				}
+				attr := parse.NewIdentifier("attr").SetTree(tree).SetPos(a.Pos)
+				name := &parse.StringNode{NodeType: parse.NodeString, Pos: a.Pos, Quoted: strconv.Quote(a.Ident[0]), Text: a.Ident[0]}
+				call := &parse.CommandNode{NodeType: parse.NodeCommand, Pos: a.Pos, Args: []parse.Node{attr, name}}
+				cmd.Args[i] = &parse.PipeNode{NodeType: parse.NodePipe, Pos: a.Pos, Line: pipe.Line, Cmds: []*parse.CommandNode{call}}
+			case *parse.PipeNode:
+				rewriteFieldArgs(tree, a)
+			}
+		}
+	}
+}
+
+// checkTemplateField checks that a field like .givenName is a queried attribute. Map keys are
+// case-sensitive, so the exact spelling of the attribute is required.
+func checkTemplateField(name string) error {
+	for _, attribute := range UserAttributes {
+		if attribute == name {
+			return nil
+		}
+		if strings.EqualFold(attribute, name) {
+			return fmt.Errorf("unknown attribute .%s, did you mean .%s?", name, attribute)
+		}
+	}
+	return fmt.Errorf("unknown attribute .%s, use attr for attributes that are not queried", name)
+}
+
+// checkTemplateVariables returns an error for the first variable of a template that is neither built-in nor defined
+func checkTemplateVariables(m *Mask) error {
+	var err error
+	for _, tmpl := range m.Template.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		walkTemplate(tmpl.Tree.Root, false, func(node parse.Node, _ bool) bool {
+			if n, ok := node.(*parse.CommandNode); ok {
+				if name, arg, ok := templateCallArg(n); ok && name == "var" && !isDefinedVariable(strings.TrimPrefix(arg, VARIABLE_PREFIX)) {
+					err = fmt.Errorf("undefined variable %q, set it with --var %s=value, built-in: %s", arg, arg, strings.Join(supportedVariables(), ", "))
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateCallArg returns the function name and the string literal argument of a call like {{date "YYYY"}}
+func templateCallArg(n *parse.CommandNode) (string, string, bool) {
+	if len(n.Args) != 2 {
+		return "", "", false
+	}
+	ident, ok := n.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return "", "", false
+	}
+	arg, ok := n.Args[1].(*parse.StringNode)
+	if !ok {
+		return "", "", false
+	}
+	return ident.Ident, arg.Text, true
+}
+
+// walkTemplate calls fn for every node of a template tree until fn returns false. onData tells
+// whether dot is still the user's attributes, range and with change it in their bodies.
+func walkTemplate(node parse.Node, onData bool, fn func(node parse.Node, onData bool) bool) bool {
+	if !fn(node, onData) {
+		return false
+	}
+	type child struct {
+		node   parse.Node
+		onData bool
+	}
+	var children []child
+	add := func(dataDot bool, nodes ...parse.Node) {
+		for _, n := range nodes {
+			children = append(children, child{n, dataDot})
+		}
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		add(onData, n.Nodes...)
+	case *parse.ActionNode:
+		add(onData, n.Pipe)
+	case *parse.IfNode:
+		add(onData, n.Pipe, n.List)
+		if n.ElseList != nil {
+			add(onData, n.ElseList)
+		}
+	case *parse.RangeNode:
+		add(onData, n.Pipe)
+		add(false, n.List)
+		if n.ElseList != nil {
+			add(onData, n.ElseList)
+		}
+	case *parse.WithNode:
+		add(onData, n.Pipe)
+		add(false, n.List)
+		if n.ElseList != nil {
+			add(onData, n.ElseList)
+		}
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			add(onData, n.Pipe)
+		}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			add(onData, cmd)
+		}
+	case *parse.CommandNode:
+		add(onData, n.Args...)
+	case *parse.ChainNode:
+		add(onData, n.Node)
+	}
+	for _, c := range children {
+		if !walkTemplate(c.node, c.onData, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// executeTemplate runs a template mask for the user and returns every non-empty, trimmed output line
+func (ctx *maskContext) executeTemplate(m *Mask) ([]string, error) {
+	tmpl, err := m.Template.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(templateFuncs(ctx))
+
+	data := make(map[string]string, len(UserAttributes))
+	for _, attribute := range UserAttributes {
+		data[attribute] = ctx.entry.GetEqualFoldAttributeValue(attribute)
+	}
+	var output strings.Builder
+	if err := tmpl.Execute(&output, data); err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, line := range strings.Split(output.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			candidates = append(candidates, line)
+		}
+	}
+	return candidates, nil
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateMasks(t *testing.T) {
+	tests := []struct {
+		template string
+		onEmpty  string
+		want     []string
+	}{
+		{`{{.givenName}}{{.sn | Upper}}!`, ON_EMPTY_SKIP, []string{"JohnDOE!"}},
+		{`{{if eq .sn "Doe"}}Admin{{else}}Welcome{{end}}1`, ON_EMPTY_SKIP, []string{"Admin1"}},
+		{`{{range values "proxyAddresses"}}{{.}}` + "\n" + `{{end}}`, ON_EMPTY_SKIP, []string{"SMTP:john.doe@corp.local", "smtp:jd@corp.local"}},
+		{`{{expand "ToggleFirst" .sn}}`, ON_EMPTY_SKIP, []string{"[Doe doe]"}},
+		// Empty printed values follow OnEmpty, conditions see the empty value
+		{`{{.department}}x`, ON_EMPTY_SKIP, nil},
+		{`{{.department}}x`, ON_EMPTY_KEEP, []string{"x"}},
+		{`{{.department}}x`, ON_EMPTY_FALLBACK, []string{"jdoex"}},
+		{`{{attr "department"}}x`, ON_EMPTY_SKIP, nil},
+		{`{{date "whenCreated:YYYY"}}x`, ON_EMPTY_SKIP, nil},
+		{`{{date "whenCreated:YYYY"}}x`, ON_EMPTY_FALLBACK, nil},
+		{`{{if .department}}{{.department}}{{else}}none{{end}}`, ON_EMPTY_SKIP, []string{"none"}},
+	}
+	for _, tt := range tests {
+		m, err := CompileMask(TEMPLATE_PREFIX + tt.template)
+		if err != nil {
+			t.Errorf("CompileMask(%q): %v", tt.template, err)
+			continue
+		}
+		got := generatePWs(testEntry(), m, GenOptions{OnEmpty: tt.onEmpty})
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("template %q with %s = %q, want %q", tt.template, tt.onEmpty, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateMaskErrors(t *testing.T) {
+	tests := map[string]string{
+		`{{.givenname}}`:   "did you mean .givenName",
+		`{{.foo}}`:         "unknown attribute .foo",
+		`{{date "YYYYY"}}`: "unknown date placeholder",
+		`{{if}}`:           "invalid template",
+	}
+	for template, want := range tests {
+		_, err := CompileMask(TEMPLATE_PREFIX + template)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CompileMask(%q) error = %v, want %q", template, err, want)
+		}
+	}
+}
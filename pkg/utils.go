@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -90,22 +92,57 @@ func PrintDebug(msg string) {
 	Print("⚙ "+msg, Blue)
 }
 
+// MaskLine is a mask read from a mask file together with its 1-based line number
+// and its weight, 0 if the line has no weight
+type MaskLine struct {
+	Line   int
+	Mask   string
+	Weight float64
+}
+
+// maskWeightRegex matches the optional weight prefix of a mask file line, e.g. "@5 Summer{YYYY}!"
+var maskWeightRegex = regexp.MustCompile(`^@(\d+(?:\.\d+)?)\s+(.+)$`)
+
 // ReadMaskFile reads a file and returns all non-empty, non-comment lines as masks.
 func ReadMaskFile(path string) ([]string, error) {
+	lines, err := ReadMaskFileLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	masks := make([]string, 0, len(lines))
+	for _, line := range lines {
+		masks = append(masks, line.Mask)
+	}
+	return masks, nil
+}
+
+// ReadMaskFileLines works like ReadMaskFile but keeps the line number of every mask
+func ReadMaskFileLines(path string) ([]MaskLine, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open mask file: %w", err)
 	}
 	defer f.Close()
 
-	var masks []string
+	var masks []MaskLine
 	scanner := bufio.NewScanner(f)
+	lineNumber := 0
 	for scanner.Scan() {
+		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		masks = append(masks, line)
+		maskLine := MaskLine{Line: lineNumber, Mask: line}
+		if matches := maskWeightRegex.FindStringSubmatch(line); matches != nil {
+			weight, err := strconv.ParseFloat(matches[1], 64)
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("%s:%d: the weight of a mask must be greater than 0", path, lineNumber)
+			}
+			maskLine.Mask, maskLine.Weight = matches[2], weight
+		}
+		masks = append(masks, maskLine)
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading mask file: %w", err)
@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// VARIABLE_PREFIX marks variables in placeholders, e.g. {$company}
+const VARIABLE_PREFIX = "$"
+
+// Built-in variables derived from the queried domain
+const (
+	VAR_DOMAIN       = "domain"
+	VAR_DOMAIN_LABEL = "domainLabel"
+	VAR_NETBIOS      = "netbios"
+	VAR_FOREST       = "forest"
+)
+
+var builtinVariables = []string{VAR_DOMAIN, VAR_DOMAIN_LABEL, VAR_NETBIOS, VAR_FOREST}
+
+// variableNameRegex matches valid variable names
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// customVariables holds the variables set with SetVariable by lower-case name
+var customVariables = map[string]string{}
+
+// DomainInfo holds the names of the queried domain used by the built-in variables
+type DomainInfo struct {
+	Domain  string `json:"domain"`
+	NetBIOS string `json:"netbios,omitempty"`
+	Forest  string `json:"forest,omitempty"`
+}
+
+// SetVariable defines a variable usable as {$name} in masks
+func SetVariable(name, value string) error {
+	if !variableNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q, use letters, digits, '_' and '-'", name)
+	}
+	if isBuiltinVariable(name) {
+		return fmt.Errorf("variable %q is built-in and cannot be overridden", name)
+	}
+	customVariables[strings.ToLower(name)] = value
+	return nil
+}
+
+// ParseVariable parses an assignment like company=Contoso. The value is not trimmed, so it may contain spaces.
+func ParseVariable(assignment string) (string, string, error) {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid variable %q, expected name=value", assignment)
+	}
+	return strings.TrimSpace(name), value, nil
+}
+
+// LoadVariablesFile sets the variables of a file with one name=value per line and returns their
+// number. Empty lines and lines starting with # are ignored.
+func LoadVariablesFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open variables file: %w", err)
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, err := ParseVariable(line)
+		if err == nil {
+			err = SetVariable(name, value)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading variables file: %w", err)
+	}
+	return count, nil
+}
+
+func isBuiltinVariable(name string) bool {
+	for _, builtin := range builtinVariables {
+		if strings.EqualFold(builtin, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVariablePlaceholder reports whether a placeholder name like $company is a syntactically valid variable
+func isVariablePlaceholder(name string) bool {
+	return strings.HasPrefix(name, VARIABLE_PREFIX) && variableNameRegex.MatchString(strings.TrimPrefix(name, VARIABLE_PREFIX))
+}
+
+// CheckVariables returns an error for the first variable of a mask that is neither built-in nor defined
+func CheckVariables(m *Mask) error {
+	if m.Template != nil {
+		return checkTemplateVariables(m)
+	}
+	var err error
+	walkPlaceholders(m.Nodes, func(placeholder *PlaceholderNode) bool {
+		for _, alternative := range placeholder.Alternatives {
+			for _, ref := range alternative.Chain {
+				if !isVariablePlaceholder(ref.Name) {
+					continue
+				}
+				name := strings.TrimPrefix(ref.Name, VARIABLE_PREFIX)
+				if _, ok := customVariables[strings.ToLower(name)]; !ok && !isBuiltinVariable(name) {
+					err = &MaskError{Mask: m.Raw, Column: ref.Col, Msg: fmt.Sprintf("undefined variable %q, set it with --var %s=value, built-in: %s", name, name, strings.Join(supportedVariables(), ", "))}
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// isDefinedVariable reports whether a variable name without prefix is built-in or was set
+func isDefinedVariable(name string) bool {
+	_, ok := customVariables[strings.ToLower(name)]
+	return ok || isBuiltinVariable(name)
+}
+
+func supportedVariables() []string {
+	names := append([]string{}, builtinVariables...)
+	var custom []string
+	for name := range customVariables {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// resolveVariable returns the value of a built-in or custom variable
+func (ctx *maskContext) resolveVariable(name string) string {
+	name = strings.TrimPrefix(name, VARIABLE_PREFIX)
+	domain := ctx.opts.Domain
+	switch strings.ToLower(name) {
+	case strings.ToLower(VAR_DOMAIN):
+		return domain.Domain
+	case strings.ToLower(VAR_DOMAIN_LABEL):
+		return domainLabel(domain.Domain)
+	case strings.ToLower(VAR_NETBIOS):
+		if domain.NetBIOS != "" {
+			return domain.NetBIOS
+		}
+		// Without the partitions container the NetBIOS name usually is the upper-case first label
+		return strings.ToUpper(domainLabel(domain.Domain))
+	case strings.ToLower(VAR_FOREST):
+		if domain.Forest != "" {
+			return domain.Forest
+		}
+		return domain.Domain
+	}
+	return customVariables[strings.ToLower(name)]
+}
+
+// domainLabel returns the first DNS label of a domain, e.g. corp for corp.local
+func domainLabel(domain string) string {
+	label, _, _ := strings.Cut(domain, ".")
+	return label
+}
+
+// dnToDomain converts a naming context like DC=corp,DC=local to corp.local
+func dnToDomain(dn string) string {
+	var labels []string
+	for _, rdn := range strings.Split(dn, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(rdn), "=")
+		if ok && strings.EqualFold(key, "DC") {
+			labels = append(labels, value)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// queryDomainInfo reads the forest from the rootDSE and the NetBIOS name from the partitions
+// container. Names that cannot be read stay empty and fall back to values derived from the domain.
+func queryDomainInfo(conn *ldap.Conn, domain, domainBase string) DomainInfo {
+	info := DomainInfo{Domain: domain}
+
+	rootDSE, err := conn.Search(ldap.NewSearchRequest(
+		"", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"configurationNamingContext", "rootDomainNamingContext"}, nil,
+	))
+	if err != nil || len(rootDSE.Entries) == 0 {
+		PrintWarning(fmt.Sprintf("Could not read the rootDSE, {$%s} and {$%s} are derived from the domain", VAR_NETBIOS, VAR_FOREST))
+		return info
+	}
+	info.Forest = dnToDomain(rootDSE.Entries[0].GetAttributeValue("rootDomainNamingContext"))
+
+	configNC := rootDSE.Entries[0].GetAttributeValue("configurationNamingContext")
+	partitions, err := conn.Search(ldap.NewSearchRequest(
+		"CN=Partitions,"+configNC, ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=crossRef)(nCName=%s))", ldap.EscapeFilter(domainBase)), []string{"nETBIOSName"}, nil,
+	))
+	if err == nil && len(partitions.Entries) > 0 {
+		info.NetBIOS = partitions.Entries[0].GetAttributeValue("nETBIOSName")
+	}
+	return info
+}